@@ -32,6 +32,34 @@ const (
 	OpArray
 	OpHash
 	OpIndex
+	OpCall
+	OpReturnValue
+	OpReturn
+	OpGetLocal
+	OpSetLocal
+	OpClosure
+	OpGetFree
+	OpCurrentClosure
+	OpGetBuiltin
+	OpIter
+	OpIterNext
+	OpSetIndex
+	OpSetFree
+	OpMod
+	OpGreaterThanOrEqual
+	OpJumpTruthy
+	OpConcat
+	OpModule
+	OpConstantWide
+	OpClosureWide
+	OpCaptureLocal
+	OpCaptureFree
+	OpLessThan
+	OpLessThanOrEqual
+	OpJumpWide
+	OpJumpNotTruthyWide
+	OpJumpTruthyWide
+	OpIterNextWide
 )
 
 type Definition struct {
@@ -61,6 +89,73 @@ var definitions = map[Opcode]*Definition{
 	OpArray:         {"OpArray", []int{2}},
 	OpHash:          {"OpHash", []int{2}},
 	OpIndex:         {"OpIndex", []int{}},
+	OpCall:          {"OpCall", []int{1}},
+	OpReturnValue:   {"OpReturnValue", []int{}},
+	OpReturn:        {"OpReturn", []int{}},
+	OpGetLocal:      {"OpGetLocal", []int{1}},
+	OpSetLocal:      {"OpSetLocal", []int{1}},
+	// OpClosure operands are the constant index of the function and the number of free variables on the stack
+	OpClosure:        {"OpClosure", []int{2, 1}},
+	OpGetFree:        {"OpGetFree", []int{1}},
+	OpCurrentClosure: {"OpCurrentClosure", []int{}},
+	OpGetBuiltin:     {"OpGetBuiltin", []int{1}},
+	OpIter:           {"OpIter", []int{}},
+	// OpIterNext pushes the next element of the iterator on top of the stack or jumps to its operand when exhausted
+	OpIterNext: {"OpIterNext", []int{2}},
+	// OpSetIndex pops the value, the index and the indexed object, stores the value and pushes it back
+	OpSetIndex: {"OpSetIndex", []int{}},
+	OpSetFree:  {"OpSetFree", []int{1}},
+	OpMod:      {"OpMod", []int{}},
+	// OpGreaterThanOrEqual pushes whether the left operand is greater than or equal to the right one
+	OpGreaterThanOrEqual: {"OpGreaterThanOrEqual", []int{}},
+	OpJumpTruthy:         {"OpJumpTruthy", []int{2}},
+	// OpConcat joins the string forms of its operand number of values on the stack into one string
+	OpConcat: {"OpConcat", []int{2}},
+	// OpModule operands are the constant index of the module name and the number of names and values on the stack
+	OpModule: {"OpModule", []int{2, 2}},
+	// OpConstantWide and OpClosureWide take constant indexes that do not fit in 2 bytes
+	OpConstantWide: {"OpConstantWide", []int{4}},
+	OpClosureWide:  {"OpClosureWide", []int{4, 1}},
+	// OpCaptureLocal and OpCaptureFree push the cell of a local or free variable for OpClosure,
+	// OpCaptureLocal moves the local into a new cell the first time it is captured
+	OpCaptureLocal: {"OpCaptureLocal", []int{1}},
+	OpCaptureFree:  {"OpCaptureFree", []int{1}},
+	// OpLessThan and OpLessThanOrEqual keep the operands of < and <= in source order,
+	// so the left one is evaluated first
+	OpLessThan:        {"OpLessThan", []int{}},
+	OpLessThanOrEqual: {"OpLessThanOrEqual", []int{}},
+	// the wide jumps take targets past the first 64 KB of instructions
+	OpJumpWide:          {"OpJumpWide", []int{4}},
+	OpJumpNotTruthyWide: {"OpJumpNotTruthyWide", []int{4}},
+	OpJumpTruthyWide:    {"OpJumpTruthyWide", []int{4}},
+	OpIterNextWide:      {"OpIterNextWide", []int{4}},
+}
+
+// wideVariants maps opcodes taking a constant index or a jump target to their
+// wide variant.
+var wideVariants = map[Opcode]Opcode{
+	OpConstant:      OpConstantWide,
+	OpClosure:       OpClosureWide,
+	OpJump:          OpJumpWide,
+	OpJumpNotTruthy: OpJumpNotTruthyWide,
+	OpJumpTruthy:    OpJumpTruthyWide,
+	OpIterNext:      OpIterNextWide,
+}
+
+// Wide returns the variant of op with a 4 byte first operand, if it has one.
+func Wide(op Opcode) (Opcode, bool) {
+	wide, ok := wideVariants[op]
+	return wide, ok
+}
+
+// Narrow returns the opcode op is the wide variant of, if it is one.
+func Narrow(op Opcode) (Opcode, bool) {
+	for narrow, wide := range wideVariants {
+		if wide == op {
+			return narrow, true
+		}
+	}
+	return op, false
 }
 
 func LookUp(op byte) (*Definition, error) {
@@ -72,10 +167,26 @@ func LookUp(op byte) (*Definition, error) {
 	return def, nil
 }
 
+// Make encodes an instruction. It panics when an operand does not fit in its
+// width, the compiler uses Encode to report those as errors instead.
 func Make(op Opcode, operands ...int) []byte {
+	if _, ok := definitions[op]; !ok {
+		return []byte{}
+	}
+
+	ins, err := Encode(op, operands...)
+	if err != nil {
+		panic(err)
+	}
+	return ins
+}
+
+// Encode encodes an instruction like Make, returning an error when op is
+// undefined or an operand does not fit in its width.
+func Encode(op Opcode, operands ...int) ([]byte, error) {
 	def, ok := definitions[op]
 	if !ok {
-		return []byte{}
+		return nil, fmt.Errorf("opcode %d undefined", op)
 	}
 
 	instructionLen := 1
@@ -89,14 +200,22 @@ func Make(op Opcode, operands ...int) []byte {
 	offset := 1
 	for i, o := range operands {
 		width := def.OperandWidths[i]
+		if o < 0 || uint64(o) >= 1<<(8*uint(width)) {
+			return nil, fmt.Errorf("operand %d of %s out of range: %d", i, def.Name, o)
+		}
+
 		switch width {
+		case 4:
+			binary.BigEndian.PutUint32(instruction[offset:], uint32(o))
 		case 2:
 			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
 		}
 		offset += width
 	}
 
-	return instruction
+	return instruction, nil
 }
 
 func (ins Instructions) String() string {
@@ -106,7 +225,8 @@ func (ins Instructions) String() string {
 	for i < len(ins) {
 		def, err := LookUp(ins[i])
 		if err != nil {
-			fmt.Fprintf(&out, "Error: %s\n", err)
+			fmt.Fprintf(&out, "%04d ERROR: %s\n", i, err)
+			i++
 			continue
 		}
 
@@ -127,14 +247,11 @@ func (ins Instructions) fmtInstruction(def *Definition, operands []int) string {
 		return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n", len(operands), operandCount)
 	}
 
-	switch operandCount {
-	case 0:
-		return def.Name
-	case 1:
-		return fmt.Sprintf("%s %d", def.Name, operands[0])
+	out := def.Name
+	for _, operand := range operands {
+		out += fmt.Sprintf(" %d", operand)
 	}
-
-	return fmt.Sprintf("ERROR: unhandled operandCount for %s\n", def.Name)
+	return out
 }
 
 func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
@@ -143,8 +260,12 @@ func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
 
 	for i, width := range def.OperandWidths {
 		switch width {
+		case 4:
+			operands[i] = int(ReadUint32(ins[offset:]))
 		case 2:
 			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
 		}
 
 		offset += width
@@ -153,6 +274,14 @@ func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
 	return operands, offset
 }
 
+func ReadUint32(ins Instructions) uint32 {
+	return binary.BigEndian.Uint32(ins)
+}
+
 func ReadUint16(ins Instructions) uint16 {
 	return binary.BigEndian.Uint16(ins)
 }
+
+func ReadUint8(ins Instructions) uint8 {
+	return uint8(ins[0])
+}
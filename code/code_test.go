@@ -13,6 +13,13 @@ func TestMake(t *testing.T) {
 		{OpSub, []int{}, []byte{byte(OpSub)}},
 		{OpMul, []int{}, []byte{byte(OpMul)}},
 		{OpDiv, []int{}, []byte{byte(OpDiv)}},
+		{OpCall, []int{255}, []byte{byte(OpCall), 255}},
+		{OpGetLocal, []int{255}, []byte{byte(OpGetLocal), 255}},
+		{OpClosure, []int{65534, 255}, []byte{byte(OpClosure), 255, 254, 255}},
+		{OpSetFree, []int{255}, []byte{byte(OpSetFree), 255}},
+		{OpJumpTruthy, []int{65534}, []byte{byte(OpJumpTruthy), 255, 254}},
+		{OpConstantWide, []int{65536}, []byte{byte(OpConstantWide), 0, 1, 0, 0}},
+		{OpClosureWide, []int{70000, 2}, []byte{byte(OpClosureWide), 0, 1, 17, 112, 2}},
 	}
 
 	for _, tt := range tests {
@@ -31,6 +38,63 @@ func TestMake(t *testing.T) {
 	}
 }
 
+func TestEncodeOutOfRange(t *testing.T) {
+	tests := []struct {
+		op       Opcode
+		operands []int
+		expected string
+	}{
+		{OpConstant, []int{65536}, "operand 0 of OpConstant out of range: 65536"},
+		{OpGetGlobal, []int{-1}, "operand 0 of OpGetGlobal out of range: -1"},
+		{OpCall, []int{256}, "operand 0 of OpCall out of range: 256"},
+		{OpClosure, []int{1, 256}, "operand 1 of OpClosure out of range: 256"},
+		{Opcode(255), []int{}, "opcode 255 undefined"},
+	}
+
+	for _, tt := range tests {
+		_, err := Encode(tt.op, tt.operands...)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error. want=%q, got=%v", tt.expected, err)
+		}
+	}
+
+	ins, err := Encode(OpConstant, 65535)
+	if err != nil {
+		t.Fatalf("Encode failed: %s", err)
+	}
+	if string(ins) != string(Make(OpConstant, 65535)) {
+		t.Errorf("Encode and Make differ. Encode=%v, Make=%v", ins, Make(OpConstant, 65535))
+	}
+}
+
+func TestWide(t *testing.T) {
+	tests := []struct {
+		op       Opcode
+		expected Opcode
+		ok       bool
+	}{
+		{OpConstant, OpConstantWide, true},
+		{OpClosure, OpClosureWide, true},
+		{OpJumpNotTruthy, OpJumpNotTruthyWide, true},
+		{OpIterNext, OpIterNextWide, true},
+		{OpGetGlobal, 0, false},
+	}
+
+	for _, tt := range tests {
+		wide, ok := Wide(tt.op)
+		if ok != tt.ok || wide != tt.expected {
+			t.Errorf("wrong wide variant of %d. want=(%d, %t), got=(%d, %t)", tt.op, tt.expected, tt.ok, wide, ok)
+		}
+
+		if !tt.ok {
+			continue
+		}
+		if narrow, ok := Narrow(wide); !ok || narrow != tt.op {
+			t.Errorf("wrong narrow variant of %d. want=%d, got=(%d, %t)", wide, tt.op, narrow, ok)
+		}
+	}
+}
+
 func TestInstructionsString(t *testing.T) {
 	instructions := []Instructions{
 		Make(OpConstant, 1),
@@ -48,6 +112,8 @@ func TestInstructionsString(t *testing.T) {
 		Make(OpGreaterThan),
 		Make(OpBang),
 		Make(OpMinus),
+		Make(OpCall, 2),
+		Make(OpClosure, 65535, 255),
 	}
 	expected := `0000 OpConstant 1
 0003 OpConstant 2
@@ -64,6 +130,8 @@ func TestInstructionsString(t *testing.T) {
 0018 OpGreaterThan
 0019 OpBang
 0020 OpMinus
+0021 OpCall 2
+0023 OpClosure 65535 255
 `
 	concatted := Instructions{}
 	for _, ins := range instructions {
@@ -76,6 +144,15 @@ func TestInstructionsString(t *testing.T) {
 	}
 }
 
+func TestInstructionsStringUndefinedOpcode(t *testing.T) {
+	ins := append(Instructions{255}, Make(OpModule, 1, 4)...)
+
+	expected := "0000 ERROR: opcode 255 undefined\n0001 OpModule 1 4\n"
+	if ins.String() != expected {
+		t.Errorf("instructions wrongly formatted.\nwant=%q\ngot=%q", expected, ins.String())
+	}
+}
+
 func TestReadOperands(t *testing.T) {
 	tests := []struct {
 		op        Opcode
@@ -83,6 +160,11 @@ func TestReadOperands(t *testing.T) {
 		bytesRead int
 	}{
 		{OpConstant, []int{65535}, 2},
+		{OpCall, []int{255}, 1},
+		{OpGetLocal, []int{255}, 1},
+		{OpClosure, []int{65535, 255}, 3},
+		{OpConstantWide, []int{1<<32 - 1}, 4},
+		{OpClosureWide, []int{65536, 255}, 5},
 	}
 	for _, tt := range tests {
 		instruction := Make(tt.op, tt.operands...)
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Soj447/gonk/compiler"
+	"github.com/Soj447/gonk/object"
+	"github.com/Soj447/gonk/token"
+	"github.com/Soj447/gonk/vm"
+)
+
+// compiled scripts find their arguments in the first global instead of a let
+// statement, since they are only known when the script is executed
+const argsGlobal = 0
+
+// compileCommand implements `gonk compile [-O] [-o file.gonkc] file.gonk`. The
+// output defaults to the script path with the .gonkc extension.
+func compileCommand(arguments []string, stderr io.Writer) int {
+	flags := flag.NewFlagSet("compile", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	output := flags.String("o", "", "output file")
+	optimize := flags.Bool("O", false, "optimize the bytecode")
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: gonk compile [-O] [-o file.gonkc] file.gonk\n")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(arguments); err != nil {
+		return exitUsage
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	path := flags.Arg(0)
+	if *output == "" {
+		*output = strings.TrimSuffix(path, filepath.Ext(path)) + ".gonkc"
+	}
+
+	bytecode, _, ok := compileScript(path, stderr)
+	if !ok {
+		return exitError
+	}
+	if *optimize {
+		bytecode = compiler.Optimize(bytecode)
+	}
+
+	data, err := compiler.Marshal(bytecode)
+	if err != nil {
+		fmt.Fprintf(stderr, "compilation failed: %s\n", err)
+		return exitError
+	}
+
+	if err := os.WriteFile(*output, data, 0644); err != nil {
+		fmt.Fprintf(stderr, "%s\n", err)
+		return exitError
+	}
+
+	return exitOK
+}
+
+// compileScript compiles the script at path for compileCommand, reporting any
+// error to stderr.
+func compileScript(path string, stderr io.Writer) (*compiler.ByteCode, *compiler.SymbolTable, bool) {
+	program, ok := loadProgram(path, stderr)
+	if !ok {
+		return nil, nil, false
+	}
+
+	symbolTable := compiler.NewSymbolTable()
+	for i, v := range object.Builtins {
+		symbolTable.DefineBuiltin(i, v.Name)
+	}
+	symbolTable.Define("args")
+
+	comp := compiler.NewWithState(symbolTable, []object.Object{})
+	if err := comp.Compile(program); err != nil {
+		fmt.Fprintf(stderr, "compilation failed: %s\n", err)
+		return nil, nil, false
+	}
+
+	return comp.ByteCode(), symbolTable, true
+}
+
+// execCommand implements `gonk exec file.gonkc [args...]`, running a script
+// compiled by compileCommand in the VM.
+func execCommand(arguments []string, stderr io.Writer) int {
+	if len(arguments) < 1 {
+		fmt.Fprintf(stderr, "usage: gonk exec file.gonkc [args...]\n")
+		return exitUsage
+	}
+
+	data, err := os.ReadFile(arguments[0])
+	if err != nil {
+		fmt.Fprintf(stderr, "%s\n", err)
+		return exitError
+	}
+
+	bytecode, err := compiler.Unmarshal(data)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %s\n", arguments[0], err)
+		return exitError
+	}
+
+	args := &object.Array{Elements: []object.Object{}}
+	for _, arg := range arguments[1:] {
+		args.Elements = append(args.Elements, &object.String{Value: arg})
+	}
+
+	globals := make([]object.Object, vm.GlobalSize)
+	globals[argsGlobal] = args
+
+	machine := vm.NewWithGlobalsStore(bytecode, globals)
+	if err := machine.Run(); err != nil {
+		fmt.Fprintf(stderr, "ERROR: %s\n", err)
+		return exitError
+	}
+
+	return exitOK
+}
+
+// disasmCommand implements `gonk disasm [-O] file.gonk|file.gonkc`, listing
+// the bytecode of a script or of an already compiled one to out. -O lists it
+// as optimized.
+func disasmCommand(arguments []string, out, stderr io.Writer) int {
+	flags := flag.NewFlagSet("disasm", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	optimize := flags.Bool("O", false, "optimize the bytecode")
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: gonk disasm [-O] file.gonk|file.gonkc\n")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(arguments); err != nil {
+		return exitUsage
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return exitUsage
+	}
+	path := flags.Arg(0)
+
+	var bytecode *compiler.ByteCode
+	var symbolTable *compiler.SymbolTable
+
+	if filepath.Ext(path) == ".gonkc" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(stderr, "%s\n", err)
+			return exitError
+		}
+
+		bytecode, err = compiler.Unmarshal(data)
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %s\n", path, err)
+			return exitError
+		}
+	} else {
+		var ok bool
+		bytecode, symbolTable, ok = compileScript(path, stderr)
+		if !ok {
+			return exitError
+		}
+	}
+
+	if *optimize {
+		bytecode = compiler.Optimize(bytecode)
+	}
+
+	// the source lines come from the files the positions point to, as far as they still exist
+	sources := make(map[string]string)
+	sourceMaps := []map[int]token.Position{bytecode.SourceMap}
+	for _, constant := range bytecode.Constants {
+		if fn, ok := constant.(*object.CompiledFunction); ok {
+			sourceMaps = append(sourceMaps, fn.SourceMap)
+		}
+	}
+	for _, sourceMap := range sourceMaps {
+		for _, pos := range sourceMap {
+			if _, ok := sources[pos.File]; ok || pos.File == "" {
+				continue
+			}
+			source, _ := os.ReadFile(pos.File)
+			sources[pos.File] = string(source)
+		}
+	}
+
+	compiler.Disassemble(out, bytecode, symbolTable, sources)
+	return exitOK
+}
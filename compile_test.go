@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompileAndExec(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"lib.gonk":    "let double = macro(x) { quote(unquote(x) * 2) }; let twice = fn(x) { double(x) };",
+		"script.gonk": "import \"./lib.gonk\" as lib;\nif (lib.twice(len(args)) != 4) { 1 + true }",
+	}
+	for name, source := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(source), 0644); err != nil {
+			t.Fatalf("could not write script: %s", err)
+		}
+	}
+
+	script := filepath.Join(dir, "script.gonk")
+	compiled := filepath.Join(dir, "script.gonkc")
+
+	var stderr bytes.Buffer
+	if code := compileCommand([]string{script}, &stderr); code != exitOK {
+		t.Fatalf("compile failed with code %d: %s", code, stderr.String())
+	}
+
+	// the script no longer needs its sources
+	for name := range files {
+		os.Remove(filepath.Join(dir, name))
+	}
+
+	if code := execCommand([]string{compiled, "a", "b"}, &stderr); code != exitOK {
+		t.Errorf("wrong exit code. want=%d, got=%d (stderr=%q)", exitOK, code, stderr.String())
+	}
+
+	stderr.Reset()
+	if code := execCommand([]string{compiled, "a", "b", "c"}, &stderr); code != exitError {
+		t.Errorf("wrong exit code. want=%d, got=%d", exitError, code)
+	}
+	if !strings.Contains(stderr.String(), "script.gonk:2:36: unsupported types for binary operation") {
+		t.Errorf("stderr does not contain the source position. got=%q", stderr.String())
+	}
+}
+
+func TestExecBuiltinError(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "script.gonk")
+	if err := os.WriteFile(script, []byte("len(1);\nputs(\"after\")"), 0644); err != nil {
+		t.Fatalf("could not write script: %s", err)
+	}
+
+	var stderr bytes.Buffer
+	if code := compileCommand([]string{script}, &stderr); code != exitOK {
+		t.Fatalf("compile failed with code %d: %s", code, stderr.String())
+	}
+
+	if code := execCommand([]string{filepath.Join(dir, "script.gonkc")}, &stderr); code != exitError {
+		t.Errorf("wrong exit code. want=%d, got=%d", exitError, code)
+	}
+	if !strings.Contains(stderr.String(), "script.gonk:1:4: argument to `len` not supported") {
+		t.Errorf("stderr does not contain the builtin error. got=%q", stderr.String())
+	}
+}
+
+func TestCompileCommandErrors(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "bad.gonk")
+	if err := os.WriteFile(script, []byte("let x = y;"), 0644); err != nil {
+		t.Fatalf("could not write script: %s", err)
+	}
+
+	var stderr bytes.Buffer
+	output := filepath.Join(dir, "out.gonkc")
+	if code := compileCommand([]string{"-o", output, script}, &stderr); code != exitError {
+		t.Errorf("wrong exit code. want=%d, got=%d", exitError, code)
+	}
+	if _, err := os.Stat(output); err == nil {
+		t.Errorf("output written for a failed compilation")
+	}
+
+	if code := compileCommand([]string{}, &stderr); code != exitUsage {
+		t.Errorf("wrong exit code without file. want=%d, got=%d", exitUsage, code)
+	}
+
+	stderr.Reset()
+	if code := execCommand([]string{script}, &stderr); code != exitError {
+		t.Errorf("wrong exit code for source file. want=%d, got=%d", exitError, code)
+	}
+	if !strings.Contains(stderr.String(), "not a gonkc file") {
+		t.Errorf("stderr does not explain the error. got=%q", stderr.String())
+	}
+}
+
+func TestDisasmCommandImports(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"lib.gonk":    "let two = 2;\nlet double = fn(x) { x * two };",
+		"script.gonk": `import "./lib.gonk" as lib; lib.double(3)`,
+	}
+	for name, source := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(source), 0644); err != nil {
+			t.Fatalf("could not write script: %s", err)
+		}
+	}
+
+	var out, stderr bytes.Buffer
+	if code := disasmCommand([]string{filepath.Join(dir, "script.gonk")}, &out, &stderr); code != exitOK {
+		t.Fatalf("disasm failed with code %d: %s", code, stderr.String())
+	}
+
+	expected := []string{
+		"OpSetGlobal 1            ; two\n",
+		"OpSetGlobal 2            ; double\n",
+		"OpGetGlobal 2            ; double\n",
+		"OpGetGlobal 1            ; two\n",
+	}
+	for _, e := range expected {
+		if !strings.Contains(out.String(), e) {
+			t.Errorf("listing does not contain %q. got=\n%s", e, out.String())
+		}
+	}
+}
+
+func TestDisasmCommand(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "script.gonk")
+	if err := os.WriteFile(script, []byte("let f = fn() { args };\nputs(f(), 2 * 3);"), 0644); err != nil {
+		t.Fatalf("could not write script: %s", err)
+	}
+
+	var stderr bytes.Buffer
+	if code := compileCommand([]string{script}, &stderr); code != exitOK {
+		t.Fatalf("compile failed with code %d: %s", code, stderr.String())
+	}
+
+	tests := []struct {
+		arguments []string
+		expected  []string
+	}{
+		{[]string{script}, []string{
+			"== main ==\n     // " + script + ":1: let f = fn() { args };\n",
+			"OpSetGlobal 1            ; f\n",
+			"== function 0 (parameters=0, locals=0) ==\n",
+			"OpGetGlobal 0            ; args\n",
+		}},
+		{[]string{filepath.Join(dir, "script.gonkc")}, []string{
+			"OpSetGlobal 1\n",
+			"     // " + script + ":2: puts(f(), 2 * 3);\n0007 OpGetBuiltin 1           ; puts\n",
+			"OpMul\n",
+		}},
+		{[]string{"-O", script}, []string{
+			"0014 OpConstant 3             ; 6\n0017 OpCall 2\n",
+		}},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if code := disasmCommand(tt.arguments, &out, &stderr); code != exitOK {
+			t.Fatalf("disasm failed with code %d: %s", code, stderr.String())
+		}
+
+		for _, expected := range tt.expected {
+			if !strings.Contains(out.String(), expected) {
+				t.Errorf("listing of %v does not contain %q. got=\n%s", tt.arguments, expected, out.String())
+			}
+		}
+	}
+
+	if code := disasmCommand([]string{}, &stderr, &stderr); code != exitUsage {
+		t.Errorf("wrong exit code without file. want=%d, got=%d", exitUsage, code)
+	}
+}
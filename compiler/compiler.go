@@ -6,7 +6,10 @@ import (
 
 	"github.com/Soj447/gonk/ast"
 	"github.com/Soj447/gonk/code"
+	"github.com/Soj447/gonk/evaluator"
 	"github.com/Soj447/gonk/object"
+	"github.com/Soj447/gonk/parser"
+	"github.com/Soj447/gonk/token"
 )
 
 type EmittedInstruction struct {
@@ -14,25 +17,75 @@ type EmittedInstruction struct {
 	Position int
 }
 
-type Compiler struct {
+type CompilationScope struct {
 	instructions        code.Instructions
-	constants           []object.Object
 	lastInstruction     EmittedInstruction
 	previousInstruction EmittedInstruction
-	symbolTable         *SymbolTable
+	sourceMap           map[int]token.Position
+	loops               []*loop
+	// targets of the jumps that do not fit their operand by position, the
+	// jumps are widened once the scope is complete
+	farJumps map[int]int
+}
+
+// loop collects the jumps of break and continue statements until their targets are known.
+type loop struct {
+	continuePos int
+	breakJumps  []int
+}
+
+type Compiler struct {
+	constants   *constantPool
+	symbolTable *SymbolTable
+
+	scopes     []CompilationScope
+	scopeIndex int
+
+	pos token.Position // position of the node being compiled
+
+	numIterators int // used to name the hidden variables holding for-in iterators
+
+	importing map[string]bool // paths of the modules being compiled, to detect import cycles
+
+	err error // error of the first instruction that could not be encoded, reported by Compile
 }
 
 func New() *Compiler {
-	return &Compiler{
+	mainScope := CompilationScope{
 		instructions:        code.Instructions{},
-		constants:           []object.Object{},
 		lastInstruction:     EmittedInstruction{},
 		previousInstruction: EmittedInstruction{},
-		symbolTable:         NewSymbolTable(),
+		sourceMap:           make(map[int]token.Position),
+	}
+
+	symbolTable := NewSymbolTable()
+	for i, v := range object.Builtins {
+		symbolTable.DefineBuiltin(i, v.Name)
+	}
+
+	return &Compiler{
+		constants:   newConstantPool([]object.Object{}),
+		symbolTable: symbolTable,
+		scopes:      []CompilationScope{mainScope},
+		scopeIndex:  0,
+		importing:   make(map[string]bool),
 	}
 }
 
+// NewWithState creates a compiler that keeps defining symbols and constants on
+// top of existing ones, e.g. to compile REPL input line by line.
+func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
+	compiler := New()
+	compiler.symbolTable = s
+	compiler.constants = newConstantPool(constants)
+	return compiler
+}
+
 func (c *Compiler) Compile(node ast.Node) error {
+	outerPos := c.pos
+	c.pos = node.Pos()
+	defer func() { c.pos = outerPos }()
+
 	switch node := node.(type) {
 	case *ast.Program:
 		for _, s := range node.Statements {
@@ -55,13 +108,79 @@ func (c *Compiler) Compile(node ast.Node) error {
 		}
 		c.emit(code.OpPop)
 	case *ast.LetStatement:
+		// a function assigning to its own name changes the binding of the let
+		// statement, so the name is defined before the function refers to it
+		fn, ok := node.Value.(*ast.FunctionLiteral)
+		if ok && fn.Name != "" && assignsTo(fn.Body, fn.Name) {
+			c.symbolTable.Define(node.Name.Value)
+		}
+
 		err := c.Compile(node.Value)
 		if err != nil {
 			return err
 		}
 
 		sym := c.symbolTable.Define(node.Name.Value)
-		c.emit(code.OpSetGlobal, sym.Index)
+		c.storeSymbol(sym)
+	case *ast.ImportStatement:
+		return c.compileImportStatement(node)
+	case *ast.WhileStatement:
+		loopStart := len(c.currentInstructions())
+		l := c.enterLoop(loopStart)
+
+		err := c.Compile(node.Condition)
+		if err != nil {
+			return err
+		}
+		exitJumpPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+		err = c.Compile(node.Body)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpJump, loopStart)
+
+		c.changeOperand(exitJumpPos, len(c.currentInstructions()))
+		c.leaveLoop(l)
+	case *ast.ForInStatement:
+		err := c.Compile(node.Iterable)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpIter)
+
+		// the iterator lives in a hidden variable so break and continue don't need to care about the stack
+		iterator := c.symbolTable.Define(fmt.Sprintf("$iterator%d", c.numIterators))
+		c.numIterators++
+		c.storeSymbol(iterator)
+
+		loopStart := len(c.currentInstructions())
+		l := c.enterLoop(loopStart)
+
+		c.loadSymbol(iterator)
+		exitJumpPos := c.emit(code.OpIterNext, 9999)
+		c.storeSymbol(c.symbolTable.Define(node.Variable.Value))
+
+		err = c.Compile(node.Body)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpJump, loopStart)
+
+		c.changeOperand(exitJumpPos, len(c.currentInstructions()))
+		c.leaveLoop(l)
+	case *ast.BreakStatement:
+		l := c.currentLoop()
+		if l == nil {
+			return fmt.Errorf("%s: break outside of loop", node.Pos())
+		}
+		l.breakJumps = append(l.breakJumps, c.emit(code.OpJump, 9999))
+	case *ast.ContinueStatement:
+		l := c.currentLoop()
+		if l == nil {
+			return fmt.Errorf("%s: continue outside of loop", node.Pos())
+		}
+		c.emit(code.OpJump, l.continuePos)
 	case *ast.IfExpression:
 		err := c.Compile(node.Condition)
 		if err != nil {
@@ -74,12 +193,12 @@ func (c *Compiler) Compile(node ast.Node) error {
 		}
 
 		// Last Pop should be removed to avoid popping last evaluated expression. It enables using consequences as an expression
-		if c.lastInstruction.Opcode == code.OpPop {
+		if c.lastInstructionIs(code.OpPop) {
 			c.removeLastInstruction()
 		}
 
 		jumpPos := c.emit(code.OpJump, 9999)
-		c.changeOperand(falseJumpPos, len(c.instructions))
+		c.changeOperand(falseJumpPos, len(c.currentInstructions()))
 
 		if node.Alternative == nil {
 			c.emit(code.OpNull)
@@ -89,28 +208,18 @@ func (c *Compiler) Compile(node ast.Node) error {
 				return err
 			}
 
-			if c.lastInstruction.Opcode == code.OpPop {
+			if c.lastInstructionIs(code.OpPop) {
 				c.removeLastInstruction()
 			}
 		}
 
-		c.changeOperand(jumpPos, len(c.instructions))
+		c.changeOperand(jumpPos, len(c.currentInstructions()))
 
 	case *ast.InfixExpression:
-		if node.Operator == "<" {
-			err := c.Compile(node.Right)
-			if err != nil {
-				return err
-			}
-
-			err = c.Compile(node.Left)
-			if err != nil {
-				return err
-			}
-
-			c.emit(code.OpGreaterThan)
-			return nil
+		if node.Operator == "&&" || node.Operator == "||" {
+			return c.compileLogicalExpression(node)
 		}
+
 		err := c.Compile(node.Left)
 		if err != nil {
 			return err
@@ -129,8 +238,16 @@ func (c *Compiler) Compile(node ast.Node) error {
 			c.emit(code.OpMul)
 		case "/":
 			c.emit(code.OpDiv)
+		case "%":
+			c.emit(code.OpMod)
+		case "<":
+			c.emit(code.OpLessThan)
+		case "<=":
+			c.emit(code.OpLessThanOrEqual)
 		case ">":
 			c.emit(code.OpGreaterThan)
+		case ">=":
+			c.emit(code.OpGreaterThanOrEqual)
 		case "==":
 			c.emit(code.OpEqual)
 		case "!=":
@@ -152,6 +269,8 @@ func (c *Compiler) Compile(node ast.Node) error {
 		default:
 			return fmt.Errorf("unknown operator %s", node.Operator)
 		}
+	case *ast.AssignExpression:
+		return c.compileAssignExpression(node)
 	case *ast.IndexExpression:
 		err := c.Compile(node.Left)
 		if err != nil {
@@ -167,10 +286,10 @@ func (c *Compiler) Compile(node ast.Node) error {
 	case *ast.Identifier:
 		sym, ok := c.symbolTable.Resolve(node.Value)
 		if !ok {
-			return fmt.Errorf("unkown symbol %s", node.Value)
+			return fmt.Errorf("%s: unkown symbol %s", node.Pos(), node.Value)
 		}
 
-		c.emit(code.OpGetGlobal, sym.Index)
+		c.loadSymbol(sym)
 	case *ast.ArrayLiteral:
 		for _, el := range node.Elements {
 			err := c.Compile(el)
@@ -204,6 +323,20 @@ func (c *Compiler) Compile(node ast.Node) error {
 	case *ast.IntegerLiteral:
 		integer := &object.Integer{Value: node.Value}
 		c.emit(code.OpConstant, c.addConstant(integer))
+	case *ast.MacroLiteral:
+		return fmt.Errorf("%s: macros can only be defined by top-level let statements", node.Pos())
+	case *ast.TemplateLiteral:
+		for _, part := range node.Parts {
+			err := c.Compile(part)
+			if err != nil {
+				return err
+			}
+		}
+
+		c.emit(code.OpConcat, len(node.Parts))
+	case *ast.FloatLiteral:
+		float := &object.Float{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(float))
 	case *ast.StringLiteral:
 		str := &object.String{Value: node.Value}
 		c.emit(code.OpConstant, c.addConstant(str))
@@ -213,56 +346,452 @@ func (c *Compiler) Compile(node ast.Node) error {
 		} else {
 			c.emit(code.OpFalse)
 		}
+	case *ast.FunctionLiteral:
+		c.enterScope()
+
+		if node.Name != "" && !assignsTo(node.Body, node.Name) {
+			c.symbolTable.DefineFunctionName(node.Name)
+		}
+
+		for _, p := range node.Parameters {
+			c.symbolTable.Define(p.Value)
+		}
+
+		err := c.Compile(node.Body)
+		if err != nil {
+			return err
+		}
+
+		// The last expression of the body is an implicit return value
+		if c.lastInstructionIs(code.OpPop) {
+			c.replaceLastPopWithReturn()
+		}
+		if !c.lastInstructionIs(code.OpReturnValue) {
+			c.emit(code.OpReturn)
+		}
+
+		freeSymbols := c.symbolTable.FreeSymbols
+		numLocals := c.symbolTable.numDefinitions
+		instructions, sourceMap := c.scopeInstructions()
+		c.leaveScope()
+
+		// push the captured variables so OpClosure can collect them from the stack
+		for _, s := range freeSymbols {
+			c.captureSymbol(s)
+		}
+
+		compiledFn := &object.CompiledFunction{
+			Instructions:  instructions,
+			NumLocals:     numLocals,
+			NumParameters: len(node.Parameters),
+			SourceMap:     sourceMap,
+		}
+		c.emit(code.OpClosure, c.addConstant(compiledFn), len(freeSymbols))
+	case *ast.ReturnStatement:
+		err := c.Compile(node.ReturnValue)
+		if err != nil {
+			return err
+		}
+
+		c.emit(code.OpReturnValue)
+	case *ast.CallExpression:
+		err := c.Compile(node.Function)
+		if err != nil {
+			return err
+		}
+
+		for _, arg := range node.Arguments {
+			err := c.Compile(arg)
+			if err != nil {
+				return err
+			}
+		}
+
+		c.emit(code.OpCall, len(node.Arguments))
 	}
-	return nil
+	return c.err
 }
 
+// emit appends an instruction to the current scope. Constant indexes beyond 2
+// bytes use the wide variant of op, other operands out of range are recorded
+// in c.err for Compile to report.
 func (c *Compiler) emit(op code.Opcode, operands ...int) int {
-	ins := code.Make(op, operands...)
+	ins, err := code.Encode(op, operands...)
+	if wide, ok := code.Wide(op); err != nil && ok {
+		op = wide
+		ins, err = code.Encode(op, operands...)
+	}
+	if err != nil {
+		c.fail(err)
+		return len(c.currentInstructions())
+	}
 	pos := c.addInstruction(ins)
 
-	c.previousInstruction = c.lastInstruction
-	c.lastInstruction = EmittedInstruction{Opcode: op, Position: pos}
+	scope := &c.scopes[c.scopeIndex]
+	scope.previousInstruction = scope.lastInstruction
+	scope.lastInstruction = EmittedInstruction{Opcode: op, Position: pos}
+	if c.pos.IsValid() {
+		scope.sourceMap[pos] = c.pos
+	}
 	return pos
 }
 
 func (c *Compiler) changeOperand(OpPos int, operand int) {
-	op := code.Opcode(c.instructions[OpPos])
-	newInstruction := code.Make(op, operand)
+	if c.err != nil {
+		return
+	}
+
+	op := code.Opcode(c.currentInstructions()[OpPos])
+	newInstruction, err := code.Encode(op, operand)
+	if _, ok := code.Wide(op); err != nil && ok {
+		scope := &c.scopes[c.scopeIndex]
+		if scope.farJumps == nil {
+			scope.farJumps = make(map[int]int)
+		}
+		scope.farJumps[OpPos] = operand
+		return
+	}
+	if err != nil {
+		c.fail(err)
+		return
+	}
 
 	c.replaceInstruction(OpPos, newInstruction)
 }
 
+// fail records the first error of encoding an instruction, at the position
+// being compiled.
+func (c *Compiler) fail(err error) {
+	if c.err == nil {
+		c.err = fmt.Errorf("%s: %s", c.pos, err)
+	}
+}
+
+// scopeInstructions returns the instructions and source map of the current
+// scope, with the jumps to targets past the first 64 KB widened.
+func (c *Compiler) scopeInstructions() (code.Instructions, map[int]token.Position) {
+	scope := c.scopes[c.scopeIndex]
+	if len(scope.farJumps) == 0 {
+		return scope.instructions, scope.sourceMap
+	}
+
+	f, _ := decode(scope.instructions, scope.sourceMap)
+	for i, in := range f.ins {
+		if target, ok := scope.farJumps[in.id]; ok {
+			f.ins[i].operands = []int{target}
+		}
+	}
+	return f.encode()
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
 func (c *Compiler) addInstruction(ins []byte) int {
-	newInstructionPos := len(c.instructions)
-	c.instructions = append(c.instructions, ins...)
+	newInstructionPos := len(c.currentInstructions())
+	c.scopes[c.scopeIndex].instructions = append(c.currentInstructions(), ins...)
 	return newInstructionPos
 }
 
 func (c *Compiler) addConstant(obj object.Object) int {
-	c.constants = append(c.constants, obj)
-	return len(c.constants) - 1
+	return c.constants.add(obj)
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
 }
 
 func (c *Compiler) removeLastInstruction() {
-	c.instructions = c.instructions[:c.lastInstruction.Position]
-	c.lastInstruction = c.previousInstruction
+	scope := &c.scopes[c.scopeIndex]
+	delete(scope.sourceMap, scope.lastInstruction.Position)
+	scope.instructions = scope.instructions[:scope.lastInstruction.Position]
+	scope.lastInstruction = scope.previousInstruction
 }
 
 func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
 	for i := 0; i < len(newInstruction); i++ {
-		c.instructions[pos+i] = newInstruction[i]
+		ins[pos+i] = newInstruction[i]
+	}
+}
+
+func (c *Compiler) replaceLastPopWithReturn() {
+	lastPos := c.scopes[c.scopeIndex].lastInstruction.Position
+	c.replaceInstruction(lastPos, code.Make(code.OpReturnValue))
+
+	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
+}
+
+func (c *Compiler) enterScope() {
+	scope := CompilationScope{
+		instructions:        code.Instructions{},
+		lastInstruction:     EmittedInstruction{},
+		previousInstruction: EmittedInstruction{},
+		sourceMap:           make(map[int]token.Position),
+	}
+	c.scopes = append(c.scopes, scope)
+	c.scopeIndex++
+
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() code.Instructions {
+	instructions := c.currentInstructions()
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+
+	c.symbolTable = c.symbolTable.Outer
+
+	return instructions
+}
+
+func (c *Compiler) storeSymbol(s Symbol) {
+	if s.Scope == GlobalScope {
+		c.emit(code.OpSetGlobal, s.Index)
+	} else {
+		c.emit(code.OpSetLocal, s.Index)
+	}
+}
+
+// compileLogicalExpression compiles && and || into jumps, so the right operand
+// is only evaluated when the left one does not decide the result. The result
+// is always a boolean.
+func (c *Compiler) compileLogicalExpression(node *ast.InfixExpression) error {
+	err := c.Compile(node.Left)
+	if err != nil {
+		return err
+	}
+
+	var trueJumpPos, falseJumpPos int
+	if node.Operator == "&&" {
+		falseJumpPos = c.emit(code.OpJumpNotTruthy, 9999)
+	} else {
+		trueJumpPos = c.emit(code.OpJumpTruthy, 9999)
+	}
+
+	err = c.Compile(node.Right)
+	if err != nil {
+		return err
+	}
+	rightFalseJumpPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if node.Operator == "||" {
+		c.changeOperand(trueJumpPos, len(c.currentInstructions()))
+	}
+	c.emit(code.OpTrue)
+	endJumpPos := c.emit(code.OpJump, 9999)
+
+	if node.Operator == "&&" {
+		c.changeOperand(falseJumpPos, len(c.currentInstructions()))
+	}
+	c.changeOperand(rightFalseJumpPos, len(c.currentInstructions()))
+	c.emit(code.OpFalse)
+
+	c.changeOperand(endJumpPos, len(c.currentInstructions()))
+	return nil
+}
+
+func (c *Compiler) compileAssignExpression(node *ast.AssignExpression) error {
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		sym, ok := c.symbolTable.Resolve(target.Value)
+		if !ok {
+			return fmt.Errorf("%s: unkown symbol %s", target.Pos(), target.Value)
+		}
+
+		err := c.Compile(node.Value)
+		if err != nil {
+			return err
+		}
+
+		switch sym.Scope {
+		case GlobalScope, LocalScope:
+			c.storeSymbol(sym)
+		case FreeScope:
+			c.emit(code.OpSetFree, sym.Index)
+		default:
+			return fmt.Errorf("%s: cannot assign to %s", target.Pos(), target.Value)
+		}
+		c.loadSymbol(sym)
+	case *ast.IndexExpression:
+		err := c.Compile(target.Left)
+		if err != nil {
+			return err
+		}
+
+		err = c.Compile(target.Index)
+		if err != nil {
+			return err
+		}
+
+		err = c.Compile(node.Value)
+		if err != nil {
+			return err
+		}
+
+		c.emit(code.OpSetIndex)
+	default:
+		return fmt.Errorf("%s: invalid assignment target %s", node.Pos(), node.Target.String())
+	}
+
+	return nil
+}
+
+// compileImportStatement compiles the imported file in place the first time it
+// is imported and keeps the resulting module in a hidden global, so later
+// imports of the same file only load it.
+func (c *Compiler) compileImportStatement(node *ast.ImportStatement) error {
+	path, err := parser.ResolveImport(node.Path.Value, node.Pos())
+	if err != nil {
+		return fmt.Errorf("%s: cannot import %q: %s", node.Pos(), node.Path.Value, err)
+	}
+
+	main := c.symbolTable
+	if main.main != nil {
+		main = main.main
+	}
+
+	hidden := "$module:" + path
+	module, ok := main.Resolve(hidden)
+	if !ok {
+		if c.importing[path] {
+			return fmt.Errorf("%s: import cycle: %q is already being imported", node.Pos(), node.Path.Value)
+		}
+
+		program, err := parser.ParseFile(path)
+		if err != nil {
+			return fmt.Errorf("%s: cannot import %q: %s", node.Pos(), node.Path.Value, err)
+		}
+
+		macroEnv := object.NewEnvironment()
+		evaluator.DefineMacros(program, macroEnv)
+		err = evaluator.ExpandMacros(program, macroEnv)
+		if err != nil {
+			return fmt.Errorf("%s: cannot import %q: %s", node.Pos(), node.Path.Value, err)
+		}
+
+		importer := c.symbolTable
+		c.symbolTable = NewModuleSymbolTable(main)
+		c.importing[path] = true
+		err = c.Compile(program)
+		moduleTable := c.symbolTable
+		c.symbolTable = importer
+		delete(c.importing, path)
+		if err != nil {
+			return err
+		}
+
+		members := 0
+		exported := make(map[string]bool)
+		for _, stmt := range program.Statements {
+			let, ok := stmt.(*ast.LetStatement)
+			if !ok || exported[let.Name.Value] {
+				continue
+			}
+			exported[let.Name.Value] = true
+
+			sym, _ := moduleTable.Resolve(let.Name.Value)
+			c.emit(code.OpConstant, c.addConstant(&object.String{Value: let.Name.Value}))
+			c.loadSymbol(sym)
+			members += 2
+		}
+		c.emit(code.OpModule, c.addConstant(&object.String{Value: node.Path.Value}), members)
+
+		module = main.Define(hidden)
+		c.storeSymbol(module)
+	}
+
+	c.loadSymbol(module)
+	c.storeSymbol(c.symbolTable.Define(node.Name.Value))
+	return nil
+}
+
+func (c *Compiler) enterLoop(continuePos int) *loop {
+	l := &loop{continuePos: continuePos}
+
+	scope := &c.scopes[c.scopeIndex]
+	scope.loops = append(scope.loops, l)
+	return l
+}
+
+// leaveLoop points the pending break jumps of l to the current end of the instructions.
+func (c *Compiler) leaveLoop(l *loop) {
+	for _, pos := range l.breakJumps {
+		c.changeOperand(pos, len(c.currentInstructions()))
 	}
+
+	scope := &c.scopes[c.scopeIndex]
+	scope.loops = scope.loops[:len(scope.loops)-1]
+}
+
+func (c *Compiler) currentLoop() *loop {
+	loops := c.scopes[c.scopeIndex].loops
+	if len(loops) == 0 {
+		return nil
+	}
+	return loops[len(loops)-1]
+}
+
+func (c *Compiler) loadSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	case FunctionScope:
+		c.emit(code.OpCurrentClosure)
+	case BuiltinScope:
+		c.emit(code.OpGetBuiltin, s.Index)
+	}
+}
+
+// captureSymbol pushes the cell of a variable captured by a closure, so the
+// closure shares it with the scope defining it.
+func (c *Compiler) captureSymbol(s Symbol) {
+	switch s.Scope {
+	case LocalScope:
+		c.emit(code.OpCaptureLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpCaptureFree, s.Index)
+	default:
+		c.loadSymbol(s)
+	}
+}
+
+// assignsTo tells whether node assigns to the variable name anywhere, nested
+// functions included.
+func assignsTo(node ast.Node, name string) bool {
+	found := false
+	ast.Modify(node, func(node ast.Node) ast.Node {
+		if assign, ok := node.(*ast.AssignExpression); ok {
+			if ident, ok := assign.Target.(*ast.Identifier); ok && ident.Value == name {
+				found = true
+			}
+		}
+		return node
+	})
+	return found
 }
 
 func (c *Compiler) ByteCode() *ByteCode {
+	instructions, sourceMap := c.scopeInstructions()
 	return &ByteCode{
-		Instructions: c.instructions,
-		Constants:    c.constants,
+		Instructions: instructions,
+		Constants:    c.constants.objects,
+		SourceMap:    sourceMap,
 	}
 }
 
 type ByteCode struct {
 	Instructions code.Instructions
 	Constants    []object.Object
+	SourceMap    map[int]token.Position
 }
@@ -0,0 +1,54 @@
+package compiler
+
+import "github.com/Soj447/gonk/object"
+
+// constantPool holds the constants of a program. Integers and strings are
+// immutable, so identical ones share the index of the first.
+type constantPool struct {
+	objects []object.Object
+	index   map[constantKey]int
+}
+
+// constantKey identifies an interned constant by its type and value.
+type constantKey struct {
+	objType object.ObjectType
+	value   interface{}
+}
+
+func newConstantPool(objects []object.Object) *constantPool {
+	p := &constantPool{objects: objects, index: make(map[constantKey]int)}
+
+	for i, obj := range objects {
+		key, interned := internKey(obj)
+		if _, ok := p.index[key]; interned && !ok {
+			p.index[key] = i
+		}
+	}
+
+	return p
+}
+
+// add returns the index of obj in the pool, adding it unless it is interned
+// and already there.
+func (p *constantPool) add(obj object.Object) int {
+	key, interned := internKey(obj)
+	if index, ok := p.index[key]; interned && ok {
+		return index
+	}
+
+	p.objects = append(p.objects, obj)
+	if interned {
+		p.index[key] = len(p.objects) - 1
+	}
+	return len(p.objects) - 1
+}
+
+func internKey(obj object.Object) (constantKey, bool) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return constantKey{object.INTEGER_OBJ, obj.Value}, true
+	case *object.String:
+		return constantKey{object.STRING_OBJ, obj.Value}, true
+	}
+	return constantKey{}, false
+}
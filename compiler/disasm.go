@@ -0,0 +1,180 @@
+package compiler
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/Soj447/gonk/code"
+	"github.com/Soj447/gonk/object"
+	"github.com/Soj447/gonk/token"
+)
+
+// Disassemble writes a listing of bytecode to out: the main program, then every
+// compiled function of the constant pool. Operands referring to constants,
+// globals and builtins are annotated with their values and names, jump targets
+// get labels, and the source lines instructions were compiled from are shown
+// before them. Globals are named after symbolTable and source lines are taken
+// from sources, keyed by file name; both may be nil.
+func Disassemble(out io.Writer, bytecode *ByteCode, symbolTable *SymbolTable, sources map[string]string) {
+	d := &disassembler{
+		out:       out,
+		constants: bytecode.Constants,
+		globals:   make(map[int]string),
+		sources:   make(map[string][]string),
+	}
+
+	if symbolTable != nil {
+		d.globals = symbolTable.GlobalNames()
+	}
+	for file, source := range sources {
+		d.sources[file] = strings.Split(source, "\n")
+	}
+
+	d.function("main", bytecode.Instructions, bytecode.SourceMap)
+	for i, constant := range bytecode.Constants {
+		if fn, ok := constant.(*object.CompiledFunction); ok {
+			fmt.Fprintln(out)
+			title := fmt.Sprintf("function %d (parameters=%d, locals=%d)", i, fn.NumParameters, fn.NumLocals)
+			d.function(title, fn.Instructions, fn.SourceMap)
+		}
+	}
+}
+
+type disassembler struct {
+	out       io.Writer
+	constants []object.Object
+	globals   map[int]string
+	sources   map[string][]string
+}
+
+func (d *disassembler) function(title string, ins code.Instructions, sourceMap map[int]token.Position) {
+	fmt.Fprintf(d.out, "== %s ==\n", title)
+
+	labels := jumpLabels(ins)
+	var line token.Position
+
+	for i := 0; i < len(ins); {
+		if label, ok := labels[i]; ok {
+			fmt.Fprintf(d.out, "%s:\n", label)
+		}
+
+		if pos, ok := sourceMap[i]; ok && (pos.File != line.File || pos.Line != line.Line) {
+			d.sourceLine(pos)
+			line = pos
+		}
+
+		def, err := code.LookUp(ins[i])
+		if err != nil {
+			fmt.Fprintf(d.out, "%04d ERROR: %s\n", i, err)
+			i++
+			continue
+		}
+
+		operands, read := code.ReadOperands(def, ins[i+1:])
+		fmt.Fprintf(d.out, "%04d %s\n", i, d.instruction(code.Opcode(ins[i]), def, operands, labels))
+
+		i += 1 + read
+	}
+
+	// jumps past the last instruction
+	if label, ok := labels[len(ins)]; ok {
+		fmt.Fprintf(d.out, "%s:\n", label)
+	}
+}
+
+func (d *disassembler) sourceLine(pos token.Position) {
+	location := fmt.Sprintf("%d", pos.Line)
+	if pos.File != "" {
+		location = fmt.Sprintf("%s:%d", pos.File, pos.Line)
+	}
+
+	lines := d.sources[pos.File]
+	if pos.Line > len(lines) {
+		fmt.Fprintf(d.out, "     // %s\n", location)
+		return
+	}
+	fmt.Fprintf(d.out, "     // %s: %s\n", location, strings.TrimSpace(lines[pos.Line-1]))
+}
+
+func (d *disassembler) instruction(op code.Opcode, def *code.Definition, operands []int, labels map[int]string) string {
+	out := def.Name
+	for i, operand := range operands {
+		if i == 0 && isJump(op) {
+			out += " " + labels[operand]
+		} else {
+			out += fmt.Sprintf(" %d", operand)
+		}
+	}
+
+	var comment string
+	switch op {
+	case code.OpConstant, code.OpConstantWide, code.OpClosure, code.OpClosureWide, code.OpModule:
+		comment = d.constant(operands[0])
+	case code.OpGetGlobal, code.OpSetGlobal:
+		comment = d.globals[operands[0]]
+	case code.OpGetBuiltin:
+		if operands[0] < len(object.Builtins) {
+			comment = object.Builtins[operands[0]].Name
+		}
+	}
+
+	if comment == "" {
+		return out
+	}
+	return fmt.Sprintf("%-24s ; %s", out, comment)
+}
+
+func (d *disassembler) constant(index int) string {
+	if index >= len(d.constants) {
+		return "unknown constant"
+	}
+
+	switch constant := d.constants[index].(type) {
+	case *object.String:
+		return fmt.Sprintf("%q", constant.Value)
+	case *object.CompiledFunction:
+		return fmt.Sprintf("function %d", index)
+	default:
+		return constant.Inspect()
+	}
+}
+
+func isJump(op code.Opcode) bool {
+	switch op {
+	case code.OpJump, code.OpJumpNotTruthy, code.OpJumpTruthy, code.OpIterNext,
+		code.OpJumpWide, code.OpJumpNotTruthyWide, code.OpJumpTruthyWide, code.OpIterNextWide:
+		return true
+	}
+	return false
+}
+
+// jumpLabels names the targets of the jumps in ins L1, L2, ... by offset.
+func jumpLabels(ins code.Instructions) map[int]string {
+	var targets []int
+	seen := make(map[int]bool)
+
+	for i := 0; i < len(ins); {
+		def, err := code.LookUp(ins[i])
+		if err != nil {
+			i++
+			continue
+		}
+
+		operands, read := code.ReadOperands(def, ins[i+1:])
+		if isJump(code.Opcode(ins[i])) && !seen[operands[0]] {
+			seen[operands[0]] = true
+			targets = append(targets, operands[0])
+		}
+
+		i += 1 + read
+	}
+
+	sort.Ints(targets)
+	labels := make(map[int]string, len(targets))
+	for i, target := range targets {
+		labels[target] = fmt.Sprintf("L%d", i+1)
+	}
+	return labels
+}
@@ -0,0 +1,113 @@
+package compiler
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Soj447/gonk/code"
+	"github.com/Soj447/gonk/lexer"
+	"github.com/Soj447/gonk/object"
+	"github.com/Soj447/gonk/parser"
+)
+
+func TestDisassemble(t *testing.T) {
+	input := `let name = "gonk";
+let greet = fn(n) {
+  if (n) { len(name) } else { 0 }
+};
+greet(true);`
+
+	comp := New()
+	if err := comp.Compile(parser.New(lexer.NewFile("main.gonk", input)).ParseProgram()); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	var out bytes.Buffer
+	Disassemble(&out, comp.ByteCode(), comp.symbolTable, map[string]string{"main.gonk": input})
+
+	expected := `== main ==
+     // main.gonk:1: let name = "gonk";
+0000 OpConstant 0             ; "gonk"
+0003 OpSetGlobal 0            ; name
+     // main.gonk:2: let greet = fn(n) {
+0006 OpClosure 2 0            ; function 2
+0010 OpSetGlobal 1            ; greet
+     // main.gonk:5: greet(true);
+0013 OpGetGlobal 1            ; greet
+0016 OpTrue
+0017 OpCall 1
+0019 OpPop
+
+== function 2 (parameters=1, locals=1) ==
+     // main.gonk:3: if (n) { len(name) } else { 0 }
+0000 OpGetLocal 0
+0002 OpJumpNotTruthy L1
+0005 OpGetBuiltin 0           ; len
+0007 OpGetGlobal 0            ; name
+0010 OpCall 1
+0012 OpJump L2
+L1:
+0015 OpConstant 1             ; 0
+L2:
+0018 OpReturnValue
+`
+
+	if out.String() != expected {
+		t.Errorf("wrong listing.\nwant=\n%s\ngot=\n%s", expected, out.String())
+	}
+}
+
+func TestDisassembleWithoutSymbols(t *testing.T) {
+	comp := New()
+	if err := comp.Compile(parse("let a = [1];\nwhile (false) { }")); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	var out bytes.Buffer
+	Disassemble(&out, comp.ByteCode(), nil, nil)
+
+	expected := `== main ==
+     // 1
+0000 OpConstant 0             ; 1
+0003 OpArray 1
+0006 OpSetGlobal 0
+L1:
+     // 2
+0009 OpFalse
+0010 OpJumpNotTruthy L2
+0013 OpJump L1
+L2:
+`
+
+	if out.String() != expected {
+		t.Errorf("wrong listing.\nwant=\n%s\ngot=\n%s", expected, out.String())
+	}
+}
+
+func TestDisassembleWideConstants(t *testing.T) {
+	bytecode := &ByteCode{
+		Instructions: concatInstructions([]code.Instructions{
+			code.Make(code.OpConstantWide, 0),
+			code.Make(code.OpClosureWide, 1, 0),
+		}),
+		Constants: []object.Object{
+			&object.String{Value: "wide"},
+			&object.CompiledFunction{Instructions: code.Make(code.OpReturn)},
+		},
+	}
+
+	var out bytes.Buffer
+	Disassemble(&out, bytecode, nil, nil)
+
+	expected := `== main ==
+0000 OpConstantWide 0         ; "wide"
+0005 OpClosureWide 1 0        ; function 1
+
+== function 1 (parameters=0, locals=0) ==
+0000 OpReturn
+`
+
+	if out.String() != expected {
+		t.Errorf("wrong listing.\nwant=\n%s\ngot=\n%s", expected, out.String())
+	}
+}
@@ -0,0 +1,551 @@
+package compiler
+
+import (
+	"math"
+	"strings"
+
+	"github.com/Soj447/gonk/code"
+	"github.com/Soj447/gonk/object"
+	"github.com/Soj447/gonk/token"
+)
+
+// Optimize returns a copy of bytecode where constant expressions are folded,
+// jumps to the next instruction are removed and so is the code after
+// unconditional jumps and returns that no jump leads to. The main program and
+// every compiled function of the constant pool are optimized, with their jump
+// targets and source maps moved to the new offsets. Folded values are appended
+// to the constant pool unless they are already in it, the constants they were
+// computed from stay in place.
+func Optimize(bytecode *ByteCode) *ByteCode {
+	o := &optimizer{constants: newConstantPool(append([]object.Object{}, bytecode.Constants...))}
+
+	for i := 0; i < len(bytecode.Constants); i++ {
+		fn, ok := bytecode.Constants[i].(*object.CompiledFunction)
+		if !ok {
+			continue
+		}
+
+		ins, sourceMap := o.optimize(fn.Instructions, fn.SourceMap)
+		o.constants.objects[i] = &object.CompiledFunction{
+			Instructions:  ins,
+			NumLocals:     fn.NumLocals,
+			NumParameters: fn.NumParameters,
+			SourceMap:     sourceMap,
+		}
+	}
+
+	ins, sourceMap := o.optimize(bytecode.Instructions, bytecode.SourceMap)
+	return &ByteCode{
+		Instructions: ins,
+		Constants:    o.constants.objects,
+		SourceMap:    sourceMap,
+	}
+}
+
+type optimizer struct {
+	constants *constantPool
+}
+
+// instruction is a decoded instruction. Its id is its offset in the original
+// instructions, the operand of a jump is the id of its target.
+type instruction struct {
+	id       int
+	op       code.Opcode
+	operands []int
+	pos      token.Position
+	hasPos   bool
+	// value is the result of folding an OpConstant, until it is added to the pool
+	value object.Object
+}
+
+// function holds the decoded instructions of the function being optimized,
+// end is the id standing for the end of the instructions.
+type function struct {
+	ins []instruction
+	end int
+}
+
+func (o *optimizer) optimize(ins code.Instructions, sourceMap map[int]token.Position) (code.Instructions, map[int]token.Position) {
+	f, ok := decode(ins, sourceMap)
+	if !ok {
+		return ins, sourceMap
+	}
+
+	for changed := true; changed; {
+		changed = o.foldConstants(f)
+		changed = f.removeNextJumps() || changed
+		changed = f.removeUnreachable() || changed
+	}
+
+	return f.encode()
+}
+
+func decode(ins code.Instructions, sourceMap map[int]token.Position) (*function, bool) {
+	f := &function{end: len(ins)}
+
+	for i := 0; i < len(ins); {
+		def, err := code.LookUp(ins[i])
+		if err != nil {
+			return nil, false
+		}
+
+		operands, read := code.ReadOperands(def, ins[i+1:])
+		pos, hasPos := sourceMap[i]
+		// encode picks the wide variants again where they are needed
+		op, _ := code.Narrow(code.Opcode(ins[i]))
+		f.ins = append(f.ins, instruction{id: i, op: op, operands: operands, pos: pos, hasPos: hasPos})
+
+		i += 1 + read
+	}
+
+	return f, true
+}
+
+// encode lays out the instructions, with the wide variant of those whose
+// operands do not fit. Widening an instruction moves the ones after it, so
+// the layout is repeated until every operand fits.
+func (f *function) encode() (code.Instructions, map[int]token.Position) {
+	ops := make([]code.Opcode, len(f.ins))
+	for i, in := range f.ins {
+		ops[i] = in.op
+	}
+
+	var offsets map[int]int
+	for widened := true; widened; {
+		offsets = make(map[int]int, len(f.ins)+1)
+		offset := 0
+		for i, in := range f.ins {
+			offsets[in.id] = offset
+			offset += instructionWidth(ops[i])
+		}
+		offsets[f.end] = offset
+
+		widened = false
+		for i, in := range f.ins {
+			if _, err := code.Encode(ops[i], in.targetOperands(offsets)...); err == nil {
+				continue
+			}
+			if wide, ok := code.Wide(ops[i]); ok {
+				ops[i] = wide
+				widened = true
+			}
+		}
+	}
+
+	ins := code.Instructions{}
+	sourceMap := make(map[int]token.Position)
+	for i, in := range f.ins {
+		if in.hasPos {
+			sourceMap[len(ins)] = in.pos
+		}
+		ins = append(ins, code.Make(ops[i], in.targetOperands(offsets)...)...)
+	}
+
+	return ins, sourceMap
+}
+
+// targetOperands returns the operands of in with the id of a jump target
+// replaced by its offset.
+func (in instruction) targetOperands(offsets map[int]int) []int {
+	if !isJump(in.op) {
+		return in.operands
+	}
+	return append([]int{offsets[in.operands[0]]}, in.operands[1:]...)
+}
+
+// instructionWidth is the number of bytes of an instruction with opcode op.
+func instructionWidth(op code.Opcode) int {
+	def, err := code.LookUp(byte(op))
+	if err != nil {
+		return 0
+	}
+
+	width := 1
+	for _, w := range def.OperandWidths {
+		width += w
+	}
+	return width
+}
+
+// nextID is the id of the instruction after the one at index i.
+func (f *function) nextID(i int) int {
+	if i+1 < len(f.ins) {
+		return f.ins[i+1].id
+	}
+	return f.end
+}
+
+func (f *function) targets() map[int]bool {
+	targets := make(map[int]bool)
+	for _, in := range f.ins {
+		if isJump(in.op) {
+			targets[in.operands[0]] = true
+		}
+	}
+	return targets
+}
+
+// replace replaces the instructions from start up to end with repl, which
+// takes over the id of the first one. Jumps to instructions that are gone
+// continue with the instruction following them.
+func (f *function) replace(start, end int, repl ...instruction) {
+	next := f.nextID(end - 1)
+	if len(repl) > 0 {
+		repl[0].id = f.ins[start].id
+		next = repl[0].id
+	}
+
+	gone := make(map[int]bool)
+	for _, in := range f.ins[start:end] {
+		if in.id != next {
+			gone[in.id] = true
+		}
+	}
+	for i := range f.ins {
+		if isJump(f.ins[i].op) && gone[f.ins[i].operands[0]] {
+			f.ins[i].operands[0] = next
+		}
+	}
+
+	f.ins = append(f.ins[:start], append(repl, f.ins[end:]...)...)
+}
+
+// removeNextJumps removes the unconditional jumps to the instruction right after them.
+func (f *function) removeNextJumps() bool {
+	changed := false
+
+	for i := 0; i < len(f.ins); i++ {
+		if f.ins[i].op == code.OpJump && f.ins[i].operands[0] == f.nextID(i) {
+			f.replace(i, i+1)
+			i--
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// removeUnreachable removes the instructions following an unconditional jump
+// or a return up to the next jump target.
+func (f *function) removeUnreachable() bool {
+	changed := false
+
+	for i := 0; i < len(f.ins); i++ {
+		switch f.ins[i].op {
+		case code.OpJump, code.OpReturnValue, code.OpReturn:
+		default:
+			continue
+		}
+
+		targets := f.targets()
+		end := i + 1
+		for end < len(f.ins) && !targets[f.ins[end].id] {
+			end++
+		}
+
+		if end > i+1 {
+			f.replace(i+1, end)
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// foldConstants replaces the operations on constant operands by their results.
+// Folding an operation may give the constant operand of the next one, so each
+// instruction is folded with the ones before it.
+func (o *optimizer) foldConstants(f *function) bool {
+	changed := false
+
+	for i := 0; i < len(f.ins); i++ {
+		start, repl, ok := o.fold(f.ins, i)
+		if !ok {
+			continue
+		}
+
+		// only the first instruction folded may be a jump target, otherwise
+		// some path would skip the operands
+		targets := f.targets()
+		jumpedInto := false
+		for _, in := range f.ins[start+1 : i+1] {
+			jumpedInto = jumpedInto || targets[in.id]
+		}
+		if jumpedInto {
+			continue
+		}
+
+		if len(repl) == 1 && repl[0].value != nil {
+			index := o.constants.add(repl[0].value)
+			if index > math.MaxUint16 {
+				repl[0].op = code.OpConstantWide
+			}
+			repl[0].operands = []int{index}
+		}
+
+		for j := range repl {
+			for _, in := range f.ins[start : i+1] {
+				if in.hasPos {
+					repl[j].pos, repl[j].hasPos = in.pos, true
+					break
+				}
+			}
+		}
+
+		f.replace(start, i+1, repl...)
+		i = start + len(repl) - 1
+		changed = true
+	}
+
+	return changed
+}
+
+// fold returns the instructions replacing the ones from start up to the
+// instruction at i, if it operates on constants only.
+func (o *optimizer) fold(ins []instruction, i int) (int, []instruction, bool) {
+	switch op := ins[i].op; op {
+	case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod:
+		if i < 2 {
+			return 0, nil, false
+		}
+		left, right := o.constant(ins[i-2]), o.constant(ins[i-1])
+		if left == nil || right == nil {
+			return 0, nil, false
+		}
+
+		result := foldBinary(op, left, right)
+		if result == nil {
+			return 0, nil, false
+		}
+		return constantInstruction(i-2, result)
+
+	case code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpGreaterThanOrEqual,
+		code.OpLessThan, code.OpLessThanOrEqual:
+		if i < 2 {
+			return 0, nil, false
+		}
+
+		result, ok := foldComparison(op, ins[i-2], ins[i-1], o.constant(ins[i-2]), o.constant(ins[i-1]))
+		if !ok {
+			return 0, nil, false
+		}
+		return i - 2, []instruction{booleanInstruction(result)}, true
+
+	case code.OpMinus:
+		if i < 1 {
+			return 0, nil, false
+		}
+
+		switch operand := o.constant(ins[i-1]).(type) {
+		case *object.Integer:
+			return constantInstruction(i-1, &object.Integer{Value: -operand.Value})
+		case *object.Float:
+			return constantInstruction(i-1, &object.Float{Value: -operand.Value})
+		}
+
+	case code.OpBang:
+		if i < 1 {
+			return 0, nil, false
+		}
+
+		truthy, ok := o.truthiness(ins[i-1])
+		if !ok {
+			return 0, nil, false
+		}
+		return i - 1, []instruction{booleanInstruction(!truthy)}, true
+
+	case code.OpConcat:
+		numParts := ins[i].operands[0]
+		if i < numParts {
+			return 0, nil, false
+		}
+
+		var out strings.Builder
+		for _, in := range ins[i-numParts : i] {
+			part := o.constant(in)
+			if part == nil {
+				return 0, nil, false
+			}
+			out.WriteString(part.Inspect())
+		}
+		return constantInstruction(i-numParts, &object.String{Value: out.String()})
+
+	case code.OpJumpNotTruthy, code.OpJumpTruthy:
+		if i < 1 {
+			return 0, nil, false
+		}
+
+		truthy, ok := o.truthiness(ins[i-1])
+		if !ok {
+			return 0, nil, false
+		}
+		if truthy == (op == code.OpJumpTruthy) {
+			return i - 1, []instruction{{op: code.OpJump, operands: ins[i].operands}}, true
+		}
+		return i - 1, nil, true
+	}
+
+	return 0, nil, false
+}
+
+// constant returns the value pushed by an OpConstant instruction, other
+// instructions have none.
+func (o *optimizer) constant(in instruction) object.Object {
+	if in.op != code.OpConstant && in.op != code.OpConstantWide {
+		return nil
+	}
+
+	switch constant := o.constants.objects[in.operands[0]].(type) {
+	case *object.Integer, *object.Float, *object.String:
+		return constant
+	}
+	return nil
+}
+
+// truthiness tells whether the value pushed by in is truthy, if it is known.
+func (o *optimizer) truthiness(in instruction) (bool, bool) {
+	switch in.op {
+	case code.OpTrue:
+		return true, true
+	case code.OpFalse, code.OpNull:
+		return false, true
+	case code.OpConstant, code.OpConstantWide:
+		return true, o.constant(in) != nil
+	}
+	return false, false
+}
+
+func constantInstruction(start int, obj object.Object) (int, []instruction, bool) {
+	return start, []instruction{{op: code.OpConstant, value: obj}}, true
+}
+
+func booleanInstruction(value bool) instruction {
+	if value {
+		return instruction{op: code.OpTrue}
+	}
+	return instruction{op: code.OpFalse}
+}
+
+// foldBinary computes the arithmetic the way the VM does, it returns nil when
+// the VM would fail.
+func foldBinary(op code.Opcode, left, right object.Object) object.Object {
+	switch {
+	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
+		leftVal := left.(*object.Integer).Value
+		rightVal := right.(*object.Integer).Value
+
+		switch op {
+		case code.OpAdd:
+			return &object.Integer{Value: leftVal + rightVal}
+		case code.OpSub:
+			return &object.Integer{Value: leftVal - rightVal}
+		case code.OpMul:
+			return &object.Integer{Value: leftVal * rightVal}
+		case code.OpDiv:
+			if rightVal != 0 {
+				return &object.Integer{Value: leftVal / rightVal}
+			}
+		case code.OpMod:
+			if rightVal != 0 {
+				return &object.Integer{Value: leftVal % rightVal}
+			}
+		}
+
+	case isNumber(left) && isNumber(right):
+		leftVal, rightVal := toFloat(left), toFloat(right)
+
+		switch op {
+		case code.OpAdd:
+			return &object.Float{Value: leftVal + rightVal}
+		case code.OpSub:
+			return &object.Float{Value: leftVal - rightVal}
+		case code.OpMul:
+			return &object.Float{Value: leftVal * rightVal}
+		case code.OpDiv:
+			return &object.Float{Value: leftVal / rightVal}
+		case code.OpMod:
+			return &object.Float{Value: math.Mod(leftVal, rightVal)}
+		}
+
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		if op == code.OpAdd {
+			return &object.String{Value: left.(*object.String).Value + right.(*object.String).Value}
+		}
+	}
+
+	return nil
+}
+
+// foldComparison compares numbers, strings and booleans, the VM compares other
+// values by identity which is not known before they exist.
+func foldComparison(op code.Opcode, leftIns, rightIns instruction, left, right object.Object) (bool, bool) {
+	if left != nil && right != nil && isNumber(left) && isNumber(right) {
+		if left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ {
+			// compare as integers, large ones do not survive the conversion
+			l, r := left.(*object.Integer).Value, right.(*object.Integer).Value
+			switch op {
+			case code.OpEqual:
+				return l == r, true
+			case code.OpNotEqual:
+				return l != r, true
+			case code.OpGreaterThan:
+				return l > r, true
+			case code.OpGreaterThanOrEqual:
+				return l >= r, true
+			case code.OpLessThan:
+				return l < r, true
+			case code.OpLessThanOrEqual:
+				return l <= r, true
+			}
+		}
+
+		leftVal, rightVal := toFloat(left), toFloat(right)
+		switch op {
+		case code.OpEqual:
+			return leftVal == rightVal, true
+		case code.OpNotEqual:
+			return leftVal != rightVal, true
+		case code.OpGreaterThan:
+			return leftVal > rightVal, true
+		case code.OpGreaterThanOrEqual:
+			return leftVal >= rightVal, true
+		case code.OpLessThan:
+			return leftVal < rightVal, true
+		case code.OpLessThanOrEqual:
+			return leftVal <= rightVal, true
+		}
+	}
+
+	if left != nil && right != nil && left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ {
+		l, r := left.(*object.String).Value, right.(*object.String).Value
+		switch op {
+		case code.OpEqual:
+			return l == r, true
+		case code.OpNotEqual:
+			return l != r, true
+		}
+	}
+
+	isBoolean := func(in instruction) bool { return in.op == code.OpTrue || in.op == code.OpFalse }
+	if isBoolean(leftIns) && isBoolean(rightIns) {
+		switch op {
+		case code.OpEqual:
+			return leftIns.op == rightIns.op, true
+		case code.OpNotEqual:
+			return leftIns.op != rightIns.op, true
+		}
+	}
+
+	return false, false
+}
+
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+func toFloat(obj object.Object) float64 {
+	if integer, ok := obj.(*object.Integer); ok {
+		return float64(integer.Value)
+	}
+	return obj.(*object.Float).Value
+}
@@ -0,0 +1,226 @@
+package compiler
+
+import (
+	"testing"
+
+	"github.com/Soj447/gonk/code"
+	"github.com/Soj447/gonk/lexer"
+	"github.com/Soj447/gonk/object"
+	"github.com/Soj447/gonk/parser"
+	"github.com/Soj447/gonk/token"
+)
+
+func TestOptimize(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			"1 + 2 * 3",
+			[]interface{}{1, 2, 3, 6, 7},
+			[]code.Instructions{
+				code.Make(code.OpConstant, 4),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			"-(1.5 + 1) % 2",
+			[]interface{}{1.5, 1, 2, 2.5, -2.5, -0.5},
+			[]code.Instructions{
+				code.Make(code.OpConstant, 5),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			// division by zero is left for the VM to report
+			"1 / 0",
+			[]interface{}{1, 0},
+			[]code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpDiv),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			`"a" + "b" + "${1 + 1}c"`,
+			[]interface{}{"a", "b", 1, "c", "ab", 2, "2c", "ab2c"},
+			[]code.Instructions{
+				code.Make(code.OpConstant, 7),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			"1 < 2; !(1 == 1.0); true != false",
+			[]interface{}{1, 2, 1.0},
+			[]code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpPop),
+				code.Make(code.OpFalse),
+				code.Make(code.OpPop),
+				code.Make(code.OpTrue),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			`"a" == "a"; "a" + "b" != "ab"`,
+			[]interface{}{"a", "b", "ab"},
+			[]code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpPop),
+				code.Make(code.OpFalse),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			// arrays are compared by identity, their results are unknown
+			"[] == []",
+			[]interface{}{},
+			[]code.Instructions{
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpEqual),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			"let x = 1; x + 2",
+			[]interface{}{1, 2},
+			[]code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			"if (1 > 2) { 10 } else { 20 }; 3333",
+			[]interface{}{1, 2, 10, 20, 3333},
+			[]code.Instructions{
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 4),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			"let x = 1; if (x) { 10 }",
+			[]interface{}{1, 10},
+			[]code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpJumpNotTruthy, 18),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpJump, 19),
+				code.Make(code.OpNull),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			"fn() { return 1 + 1; 3 }",
+			[]interface{}{
+				1,
+				3,
+				[]code.Instructions{
+					code.Make(code.OpConstant, 3),
+					code.Make(code.OpReturnValue),
+				},
+				2,
+			},
+			[]code.Instructions{
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runOptimizerTests(t, tests)
+}
+
+func TestOptimizeSourceMap(t *testing.T) {
+	input := "let x = 1 +\n2;\nif (true) { x } else { 3 };\nx()"
+
+	program := parser.New(lexer.NewFile("main.gonk", input)).ParseProgram()
+	compiler := New()
+	if err := compiler.Compile(program); err != nil {
+		t.Fatalf("compilation error: %s", err)
+	}
+
+	bytecode := Optimize(compiler.ByteCode())
+
+	// 1 + 2 folds to the constant of the 3 in the else branch
+	expectedInstructions := []code.Instructions{
+		code.Make(code.OpConstant, 2),
+		code.Make(code.OpSetGlobal, 0),
+		code.Make(code.OpGetGlobal, 0),
+		code.Make(code.OpPop),
+		code.Make(code.OpGetGlobal, 0),
+		code.Make(code.OpCall, 0),
+		code.Make(code.OpPop),
+	}
+	if err := testInstructions(expectedInstructions, bytecode.Instructions); err != nil {
+		t.Fatalf("testInstructions failed: %s", err)
+	}
+
+	expected := map[int]token.Position{
+		0:  {File: "main.gonk", Line: 1, Column: 9},
+		3:  {File: "main.gonk", Line: 1, Column: 1},
+		6:  {File: "main.gonk", Line: 3, Column: 13},
+		9:  {File: "main.gonk", Line: 3, Column: 1},
+		10: {File: "main.gonk", Line: 4, Column: 1},
+		13: {File: "main.gonk", Line: 4, Column: 2},
+	}
+	for offset, pos := range expected {
+		if bytecode.SourceMap[offset] != pos {
+			t.Errorf("wrong position at %d. want=%+v, got=%+v", offset, pos, bytecode.SourceMap[offset])
+		}
+	}
+}
+
+func TestOptimizeWideConstants(t *testing.T) {
+	constants := make([]object.Object, 65535)
+	for i := range constants {
+		constants[i] = &object.Float{Value: float64(i)}
+	}
+
+	compiler := NewWithState(NewSymbolTable(), constants)
+	if err := compiler.Compile(parse("1 + 2")); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	bytecode := Optimize(compiler.ByteCode())
+
+	expected := []code.Instructions{
+		code.Make(code.OpConstantWide, 65537),
+		code.Make(code.OpPop),
+	}
+	if err := testInstructions(expected, bytecode.Instructions); err != nil {
+		t.Fatalf("testInstructions failed: %s", err)
+	}
+	if err := testConstants([]interface{}{1, 2, 3}, bytecode.Constants[65535:]); err != nil {
+		t.Fatalf("testConstants failed: %s", err)
+	}
+}
+
+func runOptimizerTests(t *testing.T, tests []compilerTestCase) {
+	for _, tt := range tests {
+		program := parse(tt.input)
+		compiler := New()
+		err := compiler.Compile(program)
+		if err != nil {
+			t.Fatalf("Compilation error: %s", err)
+		}
+
+		bytecode := Optimize(compiler.ByteCode())
+
+		err = testInstructions(tt.expectedInstructions, bytecode.Instructions)
+		if err != nil {
+			t.Fatalf("%s: testInstructions failed: %s", tt.input, err)
+		}
+
+		err = testConstants(tt.expectedConstants, bytecode.Constants)
+		if err != nil {
+			t.Fatalf("%s: testConstants failed: %s", tt.input, err)
+		}
+	}
+}
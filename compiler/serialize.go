@@ -0,0 +1,314 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/Soj447/gonk/code"
+	"github.com/Soj447/gonk/object"
+	"github.com/Soj447/gonk/token"
+)
+
+// A .gonkc file starts with Magic and the format version, followed by the file
+// names used by source positions, the constant pool and the main function.
+// Functions are their instructions followed by their source map. Integers are
+// varint encoded and strings are prefixed by their length.
+const (
+	Magic         = "GNKC"
+	FormatVersion = 1
+)
+
+// tags of the entries in the constant pool
+const (
+	constInteger byte = iota + 1
+	constFloat
+	constString
+	constFunction
+)
+
+var errTruncated = errors.New("truncated bytecode")
+
+// Marshal encodes bytecode in the .gonkc format.
+func Marshal(bytecode *ByteCode) ([]byte, error) {
+	e := &encoder{files: make(map[string]int)}
+
+	// collect the file names first, they come before everything using them
+	e.collectFiles(bytecode.SourceMap)
+	for _, constant := range bytecode.Constants {
+		if fn, ok := constant.(*object.CompiledFunction); ok {
+			e.collectFiles(fn.SourceMap)
+		}
+	}
+
+	// map iteration order is random, keep the output deterministic
+	sort.Strings(e.fileNames)
+	for i, name := range e.fileNames {
+		e.files[name] = i
+	}
+
+	e.buf.WriteString(Magic)
+	e.uvarint(FormatVersion)
+
+	e.uvarint(uint64(len(e.fileNames)))
+	for _, name := range e.fileNames {
+		e.string(name)
+	}
+
+	e.uvarint(uint64(len(bytecode.Constants)))
+	for i, constant := range bytecode.Constants {
+		switch constant := constant.(type) {
+		case *object.Integer:
+			e.buf.WriteByte(constInteger)
+			e.varint(constant.Value)
+		case *object.Float:
+			e.buf.WriteByte(constFloat)
+			e.uvarint(math.Float64bits(constant.Value))
+		case *object.String:
+			e.buf.WriteByte(constString)
+			e.string(constant.Value)
+		case *object.CompiledFunction:
+			e.buf.WriteByte(constFunction)
+			e.uvarint(uint64(constant.NumLocals))
+			e.uvarint(uint64(constant.NumParameters))
+			e.function(constant.Instructions, constant.SourceMap)
+		default:
+			return nil, fmt.Errorf("constant %d: cannot serialize %s", i, constant.Type())
+		}
+	}
+
+	e.function(bytecode.Instructions, bytecode.SourceMap)
+
+	return e.buf.Bytes(), nil
+}
+
+type encoder struct {
+	buf bytes.Buffer
+
+	files     map[string]int
+	fileNames []string
+}
+
+func (e *encoder) collectFiles(sourceMap map[int]token.Position) {
+	for _, pos := range sourceMap {
+		if _, ok := e.files[pos.File]; !ok {
+			e.files[pos.File] = len(e.fileNames)
+			e.fileNames = append(e.fileNames, pos.File)
+		}
+	}
+}
+
+func (e *encoder) function(ins code.Instructions, sourceMap map[int]token.Position) {
+	e.uvarint(uint64(len(ins)))
+	e.buf.Write(ins)
+
+	offsets := make([]int, 0, len(sourceMap))
+	for offset := range sourceMap {
+		offsets = append(offsets, offset)
+	}
+	sort.Ints(offsets)
+
+	e.uvarint(uint64(len(offsets)))
+	for _, offset := range offsets {
+		pos := sourceMap[offset]
+		e.uvarint(uint64(offset))
+		e.uvarint(uint64(e.files[pos.File]))
+		e.uvarint(uint64(pos.Line))
+		e.uvarint(uint64(pos.Column))
+	}
+}
+
+func (e *encoder) uvarint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	e.buf.Write(b[:binary.PutUvarint(b[:], v)])
+}
+
+func (e *encoder) varint(v int64) {
+	var b [binary.MaxVarintLen64]byte
+	e.buf.Write(b[:binary.PutVarint(b[:], v)])
+}
+
+func (e *encoder) string(s string) {
+	e.uvarint(uint64(len(s)))
+	e.buf.WriteString(s)
+}
+
+// Unmarshal decodes bytecode in the .gonkc format.
+func Unmarshal(data []byte) (*ByteCode, error) {
+	if !bytes.HasPrefix(data, []byte(Magic)) {
+		return nil, errors.New("not a gonkc file")
+	}
+	d := &decoder{data: data[len(Magic):]}
+
+	if version := d.uvarint(); d.err == nil && version != FormatVersion {
+		return nil, fmt.Errorf("unsupported gonkc version %d, want %d", version, FormatVersion)
+	}
+
+	numFiles := d.length()
+	for i := 0; i < numFiles && d.err == nil; i++ {
+		d.fileNames = append(d.fileNames, d.string())
+	}
+
+	bytecode := &ByteCode{}
+
+	numConstants := d.length()
+	for i := 0; i < numConstants && d.err == nil; i++ {
+		var constant object.Object
+
+		switch tag := d.byte(); tag {
+		case constInteger:
+			constant = &object.Integer{Value: d.varint()}
+		case constFloat:
+			constant = &object.Float{Value: math.Float64frombits(d.uvarint())}
+		case constString:
+			constant = &object.String{Value: d.string()}
+		case constFunction:
+			fn := &object.CompiledFunction{NumLocals: d.length(), NumParameters: d.length()}
+			fn.Instructions, fn.SourceMap = d.function()
+			constant = fn
+		default:
+			if d.err == nil {
+				d.err = fmt.Errorf("constant %d: unknown tag %d", i, tag)
+			}
+		}
+
+		bytecode.Constants = append(bytecode.Constants, constant)
+	}
+
+	bytecode.Instructions, bytecode.SourceMap = d.function()
+
+	if d.err == nil && len(d.data) != 0 {
+		d.err = fmt.Errorf("%d trailing bytes", len(d.data))
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return bytecode, nil
+}
+
+// decoder reads the .gonkc format. After the first error every read returns
+// the zero value, so only d.err needs to be checked at the end.
+type decoder struct {
+	data []byte
+	err  error
+
+	fileNames []string
+}
+
+func (d *decoder) function() (code.Instructions, map[int]token.Position) {
+	ins := code.Instructions(d.bytes(d.length()))
+	if d.err == nil {
+		d.err = checkInstructions(ins)
+	}
+
+	sourceMap := make(map[int]token.Position)
+	numPositions := d.length()
+	for i := 0; i < numPositions && d.err == nil; i++ {
+		offset := d.length()
+
+		file := d.length()
+		if d.err == nil && file >= len(d.fileNames) {
+			d.err = fmt.Errorf("unknown file %d in source map", file)
+			break
+		}
+
+		pos := token.Position{Line: d.length(), Column: d.length()}
+		if d.err == nil {
+			pos.File = d.fileNames[file]
+		}
+		sourceMap[offset] = pos
+	}
+
+	return ins, sourceMap
+}
+
+// checkInstructions makes sure ins only holds known opcodes with all their
+// operands, so the VM can run it without reading past the end.
+func checkInstructions(ins code.Instructions) error {
+	for i := 0; i < len(ins); {
+		def, err := code.LookUp(ins[i])
+		if err != nil {
+			return fmt.Errorf("offset %d: %s", i, err)
+		}
+
+		width := 0
+		for _, w := range def.OperandWidths {
+			width += w
+		}
+		if i+1+width > len(ins) {
+			return fmt.Errorf("offset %d: %s is missing operands", i, def.Name)
+		}
+
+		i += 1 + width
+	}
+	return nil
+}
+
+func (d *decoder) byte() byte {
+	b := d.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (d *decoder) bytes(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n > len(d.data) {
+		d.err = errTruncated
+		return nil
+	}
+
+	b := d.data[:n:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.err = errTruncated
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+
+	v, n := binary.Varint(d.data)
+	if n <= 0 {
+		d.err = errTruncated
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+// length reads a count, size, offset or position.
+func (d *decoder) length() int {
+	v := d.uvarint()
+	if v > uint64(math.MaxInt32) {
+		if d.err == nil {
+			d.err = fmt.Errorf("invalid length %d", v)
+		}
+		return 0
+	}
+	return int(v)
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes(d.length()))
+}
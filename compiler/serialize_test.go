@@ -0,0 +1,102 @@
+package compiler
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/Soj447/gonk/code"
+	"github.com/Soj447/gonk/lexer"
+	"github.com/Soj447/gonk/object"
+	"github.com/Soj447/gonk/parser"
+)
+
+func TestMarshalRoundTrip(t *testing.T) {
+	input := `
+let pi = 3.14;
+let greet = fn(name) { "hello ${name}" };
+let adder = fn(a) { fn(b) { a + b - 100 } };
+greet("gonk");
+adder(-1)(2);
+`
+
+	p := parser.New(lexer.NewFile("main.gonk", input))
+	comp := New()
+	if err := comp.Compile(p.ParseProgram()); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	bytecode := comp.ByteCode()
+
+	data, err := Marshal(bytecode)
+	if err != nil {
+		t.Fatalf("Marshal failed: %s", err)
+	}
+
+	if !bytes.HasPrefix(data, []byte(Magic)) {
+		t.Errorf("data does not start with %q", Magic)
+	}
+
+	again, err := Marshal(bytecode)
+	if err != nil || !bytes.Equal(data, again) {
+		t.Errorf("Marshal is not deterministic")
+	}
+
+	decoded, err := Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Unmarshal failed: %s", err)
+	}
+
+	if !reflect.DeepEqual(bytecode, decoded) {
+		t.Errorf("decoded bytecode differs.\nwant=%#v\ngot=%#v", bytecode, decoded)
+	}
+
+	pos := decoded.SourceMap[0]
+	if pos.File != "main.gonk" || pos.Line != 2 || pos.Column != 10 {
+		t.Errorf("wrong source position of first instruction. got=%s", pos)
+	}
+}
+
+func TestMarshalErrors(t *testing.T) {
+	bytecode := &ByteCode{Constants: []object.Object{&object.Boolean{Value: true}}}
+
+	_, err := Marshal(bytecode)
+	if err == nil || err.Error() != "constant 0: cannot serialize BOOLEAN" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	valid, err := Marshal(&ByteCode{
+		Instructions: code.Instructions(code.Make(code.OpConstant, 0)),
+		Constants:    []object.Object{&object.String{Value: "abc"}},
+	})
+	if err != nil {
+		t.Fatalf("Marshal failed: %s", err)
+	}
+
+	tests := []struct {
+		data     []byte
+		expected string
+	}{
+		{[]byte("#!/bin/gonk"), "not a gonkc file"},
+		{[]byte(Magic + "\x02"), "unsupported gonkc version 2, want 1"},
+		{[]byte(Magic + "\x01\x00\x01\x09"), "constant 0: unknown tag 9"},
+		{[]byte(Magic + "\x01\x00\x00\x01\xff\x00"), "offset 0: opcode 255 undefined"},
+		{[]byte(Magic + "\x01\x00\x00\x01\x00\x00"), "offset 0: OpConstant is missing operands"},
+		{[]byte(Magic + "\x01\x00\x00\x00\x01\x00\x00\x01\x01"), "unknown file 0 in source map"},
+		{append(valid, 0), "1 trailing bytes"},
+	}
+
+	for _, tt := range tests {
+		_, err := Unmarshal(tt.data)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error for %q. want=%q, got=%v", tt.data, tt.expected, err)
+		}
+	}
+
+	for i := len(Magic); i < len(valid); i++ {
+		if _, err := Unmarshal(valid[:i]); err != errTruncated {
+			t.Errorf("wrong error for data truncated to %d bytes. got=%v", i, err)
+		}
+	}
+}
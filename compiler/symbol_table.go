@@ -1,9 +1,19 @@
 package compiler
 
+import (
+	"sort"
+
+	"github.com/Soj447/gonk/object"
+)
+
 type SymbolScope string
 
 const (
-	GlobalScope SymbolScope = "GLOBAL"
+	GlobalScope   SymbolScope = "GLOBAL"
+	LocalScope    SymbolScope = "LOCAL"
+	FreeScope     SymbolScope = "FREE"
+	FunctionScope SymbolScope = "FUNCTION"
+	BuiltinScope  SymbolScope = "BUILTIN"
 )
 
 type Symbol struct {
@@ -13,24 +23,162 @@ type Symbol struct {
 }
 
 type SymbolTable struct {
+	Outer *SymbolTable
+
 	store          map[string]Symbol
 	numDefinitions int
+
+	// FreeSymbols holds the original symbols of the enclosing scopes captured by this one
+	FreeSymbols []Symbol
+
+	// main is the global table of the program when this is the global table of an imported module
+	main *SymbolTable
+
+	// moduleGlobals names the globals allocated by the imported modules, by index
+	moduleGlobals map[int]string
 }
 
 func NewSymbolTable() *SymbolTable {
 	store := make(map[string]Symbol)
-	return &SymbolTable{store: store}
+	free := []Symbol{}
+	return &SymbolTable{store: store, FreeSymbols: free}
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	st := NewSymbolTable()
+	st.Outer = outer
+	return st
+}
+
+// NewModuleSymbolTable creates the global table of a module imported by the
+// program with the global table main. The module has its own names but its
+// globals are allocated next to the ones of main.
+func NewModuleSymbolTable(main *SymbolTable) *SymbolTable {
+	if main.main != nil {
+		main = main.main
+	}
+
+	st := NewSymbolTable()
+	st.main = main
+	for i, v := range object.Builtins {
+		st.DefineBuiltin(i, v.Name)
+	}
+	return st
 }
 
 func (st *SymbolTable) Define(identifier string) Symbol {
-	s := Symbol{Name: identifier, Scope: GlobalScope, Index: st.numDefinitions}
+	s := Symbol{Name: identifier, Index: st.numDefinitions}
+	if st.Outer == nil {
+		s.Scope = GlobalScope
+	} else {
+		s.Scope = LocalScope
+	}
+
+	// redefining a name in the same scope rebinds its slot, like Environment.Set in the evaluator
+	if existing, ok := st.store[identifier]; ok && existing.Scope == s.Scope {
+		return existing
+	}
+
+	if st.main != nil {
+		s.Index = st.main.numDefinitions
+		st.main.numDefinitions++
+		if st.main.moduleGlobals == nil {
+			st.main.moduleGlobals = make(map[int]string)
+		}
+		st.main.moduleGlobals[s.Index] = identifier
+	}
+
 	st.store[identifier] = s
 	st.numDefinitions++
 
 	return s
 }
 
+func (st *SymbolTable) DefineBuiltin(index int, name string) Symbol {
+	s := Symbol{Name: name, Scope: BuiltinScope, Index: index}
+	st.store[name] = s
+	return s
+}
+
+func (st *SymbolTable) DefineFunctionName(name string) Symbol {
+	s := Symbol{Name: name, Scope: FunctionScope, Index: 0}
+	st.store[name] = s
+	return s
+}
+
+func (st *SymbolTable) defineFree(original Symbol) Symbol {
+	st.FreeSymbols = append(st.FreeSymbols, original)
+
+	s := Symbol{Name: original.Name, Scope: FreeScope, Index: len(st.FreeSymbols) - 1}
+	st.store[original.Name] = s
+
+	return s
+}
+
 func (st *SymbolTable) Resolve(identifier string) (Symbol, bool) {
 	sym, ok := st.store[identifier]
+	if !ok && st.Outer != nil {
+		sym, ok = st.Outer.Resolve(identifier)
+		if !ok {
+			return sym, ok
+		}
+
+		if sym.Scope == GlobalScope || sym.Scope == BuiltinScope {
+			return sym, ok
+		}
+
+		return st.defineFree(sym), true
+	}
 	return sym, ok
 }
+
+// Copy returns a table with the same definitions that can be extended without
+// affecting the original. The outer table is shared.
+func (st *SymbolTable) Copy() *SymbolTable {
+	c := NewSymbolTable()
+	c.Outer = st.Outer
+	c.numDefinitions = st.numDefinitions
+	c.FreeSymbols = append(c.FreeSymbols, st.FreeSymbols...)
+	for name, sym := range st.store {
+		c.store[name] = sym
+	}
+	for index, name := range st.moduleGlobals {
+		if c.moduleGlobals == nil {
+			c.moduleGlobals = make(map[int]string)
+		}
+		c.moduleGlobals[index] = name
+	}
+	return c
+}
+
+// Symbols returns the symbols defined directly in this table ordered by scope and index.
+// GlobalNames returns the names of the globals by index, including the ones
+// defined by imported modules.
+func (st *SymbolTable) GlobalNames() map[int]string {
+	names := make(map[int]string, len(st.moduleGlobals))
+	for index, name := range st.moduleGlobals {
+		names[index] = name
+	}
+	for _, sym := range st.store {
+		if sym.Scope == GlobalScope {
+			names[sym.Index] = sym.Name
+		}
+	}
+	return names
+}
+
+func (st *SymbolTable) Symbols() []Symbol {
+	symbols := make([]Symbol, 0, len(st.store))
+	for _, sym := range st.store {
+		symbols = append(symbols, sym)
+	}
+
+	sort.Slice(symbols, func(i, j int) bool {
+		if symbols[i].Scope != symbols[j].Scope {
+			return symbols[i].Scope < symbols[j].Scope
+		}
+		return symbols[i].Index < symbols[j].Index
+	})
+
+	return symbols
+}
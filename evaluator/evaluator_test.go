@@ -1,7 +1,13 @@
 package evaluator
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/Soj447/gonk/lexer"
 	"github.com/Soj447/gonk/object"
@@ -22,6 +28,8 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"10 * (3 + 2)", 50},
 		{"-50 * 2", -100},
 		{"5 - 1", 4},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
 	}
 
 	for _, tt := range tests {
@@ -30,6 +38,27 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"2.5", 2.5},
+		{"-2.5", -2.5},
+		{"1.5 + 1.25", 2.75},
+		{"1 + 0.5", 1.5},
+		{"0.5 * 4", 2},
+		{"7 / 2.0", 3.5},
+		{"10 - 2.5 * 2", 5},
+		{"7.5 % 2", 1.5},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+}
+
 func TestEvalBooleanExpression(t *testing.T) {
 	tests := []struct {
 		input     string
@@ -52,6 +81,23 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"false == false", true},
 		{"true == false", false},
 		{"true != false", true},
+		{"1.5 < 2", true},
+		{"2 > 1.5", true},
+		{"1.0 == 1", true},
+		{"1.5 != 1.5", false},
+		{"1 <= 1", true},
+		{"2 <= 1", false},
+		{"1 >= 2", false},
+		{"2 >= 2", true},
+		{"1.5 >= 1", true},
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 && 2", true},
+		{"if (false) { 1 } || 0", true},
+		{"false && (1 + true)", false},
+		{"true || (1 + true)", true},
 	}
 
 	for _, tt := range tests {
@@ -160,6 +206,9 @@ func TestErrorHandling(t *testing.T) {
 		{"foobar", "identifier not found: foobar"},
 		{`"flower" - "gaze"`, "unknown operator: STRING - STRING"},
 		{`{"name": "Monkey"}[fn(x) { x }];`, "type FUNCTION is not hashable"},
+		{"1 / 0", "division by zero"},
+		{"1 % 0", "division by zero"},
+		{"true && (1 + true)", "type mismatch: INTEGER + BOOLEAN"},
 	}
 
 	for _, tt := range tests {
@@ -175,6 +224,32 @@ func TestErrorHandling(t *testing.T) {
 	}
 }
 
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedLine int
+		expectedCol  int
+	}{
+		{"5 + true", 1, 3},
+		{"let x = 1;\nlet f = fn() {\n  -true\n};\nf()", 3, 3},
+		{"\n\n   foobar", 3, 4},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Pos.Line != tt.expectedLine || errObj.Pos.Column != tt.expectedCol {
+			t.Errorf("wrong error position. expected=%d:%d, got=%s",
+				tt.expectedLine, tt.expectedCol, errObj.Pos)
+		}
+	}
+}
+
 func TestLetStatements(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -258,6 +333,24 @@ func TestStringConcatenation(t *testing.T) {
 	}
 }
 
+func TestTemplateLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let name = "Ann"; "hello ${name}!"`, "hello Ann!"},
+		{`let items = [1, 2]; "you have ${len(items)} items"`, "you have 2 items"},
+		{`"${1 + 1}${"b"}${true} ${[1, "x"]} ${1.5}"`, "2btrue [1, x] 1.5"},
+		{`"outer ${"inner ${1 * 3}"}"`, "outer inner 3"},
+		{`"price: \${x}"`, "price: ${x}"},
+		{`"${1 + true}"`, "type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		testLoopResult(t, tt.input, tt.expected)
+	}
+}
+
 func TestBuiltinFunctions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -266,6 +359,8 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("")`, 0},
 		{`len("flower")`, 6},
 		{`len("hell yeah")`, 9},
+		{`len("héllo wörld")`, 11},
+		{`len("\u{1F600}")`, 1},
 		{`len(1)`, "argument to `len` not supported. got INTEGER"},
 		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
 		{`len([1, 2, 3])`, 3},
@@ -439,6 +534,192 @@ func TestHashIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestWhileStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i = 0; while (i < 5) { let i = i + 1; }; i", 5},
+		{"let i = 0; while (true) { let i = i + 1; if (i > 3) { break; } }; i", 4},
+		{"let i = 0; let s = 0; while (i < 5) { let i = i + 1; if (i == 2) { continue; } let s = s + i; }; s", 13},
+		{"let f = fn() { while (true) { return 7; } }; f()", 7},
+		{"let f = fn() { while (false) { } }; f()", nil},
+		{"while (true) { 1 + true }", "type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		testLoopResult(t, tt.input, tt.expected)
+	}
+}
+
+func TestForInStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let s = 0; for (x in [1, 2, 3]) { let s = s + x; }; s", 6},
+		{"let s = 0; for (x in [1, 2, 3, 4]) { if (x == 2) { continue; } if (x == 4) { break; } let s = s + x; }; s", 4},
+		{`let s = ""; for (k in {"b": 1, "a": 2, "c": 3}) { let s = s + k; }; s`, "abc"},
+		{`let s = ""; for (c in "abc") { let s = c + s; }; s`, "cba"},
+		{"let f = fn(arr) { for (x in arr) { if (x > 1) { return x; } } }; f([1, 5, 9])", 5},
+		{"for (x in 5) { }", "type INTEGER is not iterable"},
+	}
+
+	for _, tt := range tests {
+		testLoopResult(t, tt.input, tt.expected)
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let a = 1; a = 2; a", 2},
+		{"let a = 1; let b = a = 3; a + b", 6},
+		{"let a = 1; (a = a + 1) + 0; a", 2},
+		{"let a = 1; let f = fn() { a = a + 1; }; f(); f(); a", 3},
+		{"let i = 0; while (i < 5) { i = i + 1; }; i", 5},
+		{"let arr = [1, 2, 3]; arr[1] = 5; arr[0] + arr[1] + arr[2]", 9},
+		{`let h = {"a": 1}; h["b"] = 2; h["a"] = h["a"] + 10; h["a"] + h["b"]`, 13},
+		{"let grid = [[0, 0], [0, 0]]; grid[1][0] = 7; grid[1][0]", 7},
+		{"let f = fn(arr) { arr[0] = 42; }; let a = [1]; f(a); a[0]", 42},
+		{"b = 1", "identifier not found: b"},
+		{"let arr = [1]; arr[1] = 2", "index out of range: 1"},
+		{"let h = {}; h[fn() {}] = 2", "type FUNCTION is not hashable"},
+		{`let s = "abc"; s[0] = "x"`, "index assignment not supported: STRING"},
+	}
+
+	for _, tt := range tests {
+		testLoopResult(t, tt.input, tt.expected)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	timeout, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	tests := []struct {
+		input    string
+		ctx      context.Context
+		limits   object.Limits
+		expected error
+	}{
+		{"while (true) { }", context.Background(), object.Limits{MaxSteps: 1000}, &object.StepLimitError{}},
+		{"while (true) { }", timeout, object.Limits{}, context.DeadlineExceeded},
+		{"let f = fn(n) { f(n + 1) }; f(0)", context.Background(), object.Limits{MaxCallDepth: 100}, &object.CallDepthError{}},
+		{`let s = "abc"; s + s`, context.Background(), object.Limits{MaxAllocSize: 5}, &object.AllocLimitError{}},
+		{`let s = "abc"; "${s}${s}"`, context.Background(), object.Limits{MaxAllocSize: 5}, &object.AllocLimitError{}},
+		{"[1, 2, 3]", context.Background(), object.Limits{MaxAllocSize: 2}, &object.AllocLimitError{}},
+		{"push([1, 2], 3)", context.Background(), object.Limits{MaxAllocSize: 2}, &object.AllocLimitError{}},
+	}
+
+	for _, tt := range tests {
+		env := object.NewEnvironment()
+		evaluated := EvalContext(tt.ctx, parser.New(lexer.New(tt.input)).ParseProgram(), env, tt.limits)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+
+		if !isLimitError(errObj.Err, tt.expected) {
+			t.Errorf("wrong error for %q. want=%T, got=%T (%s)", tt.input, tt.expected, errObj.Err, errObj.Message)
+		}
+
+		if env.Monitor() != nil {
+			t.Errorf("monitor left in environment after %q", tt.input)
+		}
+	}
+
+	evaluated := EvalContext(context.Background(), parser.New(lexer.New("let f = fn(n) { if (n > 0) { f(n - 1) } else { 0 } }; f(10)")).ParseProgram(),
+		object.NewEnvironment(), object.Limits{MaxCallDepth: 11, MaxSteps: 1000})
+	testIntegerObject(t, evaluated, 0)
+}
+
+func isLimitError(err, expected error) bool {
+	switch expected.(type) {
+	case *object.StepLimitError:
+		var target *object.StepLimitError
+		return errors.As(err, &target)
+	case *object.CallDepthError:
+		var target *object.CallDepthError
+		return errors.As(err, &target)
+	case *object.AllocLimitError:
+		var target *object.AllocLimitError
+		return errors.As(err, &target)
+	}
+	return errors.Is(err, expected)
+}
+
+func TestImports(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"math.gonk":    "let two = 2; let double = fn(n) { n * two };",
+		"counter.gonk": "let count = 0; let inc = fn() { count = count + 1 };",
+		"nested.gonk":  `import "./math.gonk" as m; let quad = fn(n) { m.double(m.double(n)) };`,
+		"a.gonk":       `import "./b.gonk" as b;`,
+		"b.gonk":       `import "./a.gonk" as a;`,
+	})
+	math := filepath.Join(dir, "math.gonk")
+	counter := filepath.Join(dir, "counter.gonk")
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{fmt.Sprintf("import %q as math; math.double(math.two)", math), 4},
+		{fmt.Sprintf("import %q as nested; nested.quad(3)", filepath.Join(dir, "nested.gonk")), 12},
+		{fmt.Sprintf("import %q as a; import %q as b; a.inc(); b.inc(); a.inc()", counter, counter), 3},
+		{fmt.Sprintf("import %q as math; math.three", math), fmt.Sprintf("module %q has no member three", math)},
+		{fmt.Sprintf("import %q as math; math.two = 3", math), "index assignment not supported: MODULE"},
+		{fmt.Sprintf("import %q as a;", filepath.Join(dir, "a.gonk")), `import cycle: "./a.gonk" is already being imported`},
+	}
+
+	for _, tt := range tests {
+		testLoopResult(t, tt.input, tt.expected)
+	}
+}
+
+// writeModules writes the source files to a temporary directory and returns it.
+func writeModules(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, source := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(source), 0644)
+		if err != nil {
+			t.Fatalf("could not write module: %s", err)
+		}
+	}
+	return dir
+}
+
+func testLoopResult(t *testing.T, input string, expected interface{}) {
+	evaluated := testEval(input)
+
+	switch expected := expected.(type) {
+	case int:
+		testIntegerObject(t, evaluated, int64(expected))
+	case string:
+		if errObj, ok := evaluated.(*object.Error); ok {
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+			return
+		}
+
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+			return
+		}
+		if str.Value != expected {
+			t.Errorf("String has wrong value. got=%q, want=%q", str.Value, expected)
+		}
+	default:
+		testNullObject(t, evaluated)
+	}
+}
+
 func testNullObject(t *testing.T, obj object.Object) bool {
 	if obj != NULL {
 		t.Errorf("object is not NULL. got=%T (%+v)", obj, obj)
@@ -470,6 +751,20 @@ func testIntegerObject(t *testing.T, obj object.Object, exptected int64) bool {
 	return true
 }
 
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%g, expected=%g", result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
 func testBooleanObject(t *testing.T, obj object.Object, exptected bool) bool {
 	result, ok := obj.(*object.Boolean)
 	if !ok {
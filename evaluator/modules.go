@@ -0,0 +1,69 @@
+package evaluator
+
+import (
+	"github.com/Soj447/gonk/ast"
+	"github.com/Soj447/gonk/object"
+	"github.com/Soj447/gonk/parser"
+)
+
+// evalImportStatement returns the module for the imported file, loading it the
+// first time it is imported anywhere in the program.
+func evalImportStatement(node *ast.ImportStatement, env *object.Environment) object.Object {
+	path, err := parser.ResolveImport(node.Path.Value, node.Pos())
+	if err != nil {
+		return newError("cannot import %q: %s", node.Path.Value, err)
+	}
+
+	module, ok := env.Module(path)
+	if ok && module == nil {
+		return newError("import cycle: %q is already being imported", node.Path.Value)
+	}
+	if ok {
+		return module
+	}
+
+	program, err := parser.ParseFile(path)
+	if err != nil {
+		return newError("cannot import %q: %s", node.Path.Value, err)
+	}
+
+	macroEnv := object.NewEnvironment()
+	DefineMacros(program, macroEnv)
+	if err := ExpandMacros(program, macroEnv); err != nil {
+		return newError("cannot import %q: %s", node.Path.Value, err)
+	}
+
+	env.SetModule(path, nil)
+	moduleEnv := object.NewModuleEnvironment(env)
+	result := Eval(program, moduleEnv)
+	if isError(result) {
+		env.DropModule(path)
+		return result
+	}
+
+	module = &object.Module{Name: node.Path.Value, Members: make(map[string]object.Object)}
+	for _, stmt := range program.Statements {
+		if let, ok := stmt.(*ast.LetStatement); ok {
+			module.Members[let.Name.Value], _ = moduleEnv.Get(let.Name.Value)
+		}
+	}
+
+	env.SetModule(path, module)
+	return module
+}
+
+func evalModuleIndexExpression(module, index object.Object) object.Object {
+	moduleObject := module.(*object.Module)
+
+	name, ok := index.(*object.String)
+	if !ok {
+		return newError("module members are indexed by name, got %s", index.Type())
+	}
+
+	member, ok := moduleObject.Members[name.Value]
+	if !ok {
+		return newError("module %q has no member %s", moduleObject.Name, name.Value)
+	}
+
+	return member
+}
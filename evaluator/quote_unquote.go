@@ -0,0 +1,70 @@
+package evaluator
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/Soj447/gonk/ast"
+	"github.com/Soj447/gonk/object"
+	"github.com/Soj447/gonk/token"
+)
+
+func quote(node ast.Node, env *object.Environment) object.Object {
+	node = evalUnquoteCalls(node, env)
+	return &object.Quote{Node: node}
+}
+
+// evalUnquoteCalls replaces every unquote(...) call below quoted with the AST
+// form of its evaluated argument.
+func evalUnquoteCalls(quoted ast.Node, env *object.Environment) ast.Node {
+	return ast.Modify(quoted, func(node ast.Node) ast.Node {
+		call, ok := node.(*ast.CallExpression)
+		if !ok || !isUnquoteCall(call) {
+			return node
+		}
+
+		if len(call.Arguments) != 1 {
+			return node
+		}
+
+		unquoted := Eval(call.Arguments[0], env)
+		converted := convertObjectToASTNode(unquoted, call.Pos())
+		if converted == nil {
+			// leave the call in place, so evaluating it reports the problem
+			return node
+		}
+		return converted
+	})
+}
+
+func isUnquoteCall(call *ast.CallExpression) bool {
+	return call.Function.TokenLiteral() == "unquote"
+}
+
+// convertObjectToASTNode turns obj back into source, positioned at pos. It
+// returns nil for objects that have no literal form.
+func convertObjectToASTNode(obj object.Object, pos token.Position) ast.Node {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		t := token.Token{Type: token.INT, Literal: fmt.Sprintf("%d", obj.Value), Pos: pos}
+		return &ast.IntegerLiteral{Token: t, Value: obj.Value}
+	case *object.Float:
+		t := token.Token{Type: token.FLOAT, Literal: strconv.FormatFloat(obj.Value, 'g', -1, 64), Pos: pos}
+		return &ast.FloatLiteral{Token: t, Value: obj.Value}
+	case *object.String:
+		t := token.Token{Type: token.STRING, Literal: obj.Value, Pos: pos}
+		return &ast.StringLiteral{Token: t, Value: obj.Value}
+	case *object.Boolean:
+		var t token.Token
+		if obj.Value {
+			t = token.Token{Type: token.TRUE, Literal: "true", Pos: pos}
+		} else {
+			t = token.Token{Type: token.FALSE, Literal: "false", Pos: pos}
+		}
+		return &ast.Boolean{Token: t, Value: obj.Value}
+	case *object.Quote:
+		return obj.Node
+	default:
+		return nil
+	}
+}
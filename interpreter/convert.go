@@ -0,0 +1,456 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/Soj447/gonk/object"
+)
+
+var (
+	objectType = reflect.TypeOf((*object.Object)(nil)).Elem()
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// ToObject converts a Go value to a gonk object. Integers, floats, strings and
+// bools map to their gonk types, slices and arrays to arrays, maps to hashes
+// and structs to hashes keyed by their exported field names, or by the name
+// in a `gonk:"name"` tag. Functions become builtins that convert their
+// arguments with FromObject; a non-nil error as last result is returned to the
+// script as an error. nil and nil pointers convert to nil, which the engines
+// treat as null, and to null inside arrays and hashes.
+func ToObject(v interface{}) (object.Object, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return toObject(reflect.ValueOf(v))
+}
+
+func toObject(v reflect.Value) (object.Object, error) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Func:
+		if v.IsNil() {
+			return nil, nil
+		}
+	}
+
+	if v.Type().Implements(objectType) {
+		return v.Interface().(object.Object), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return object.TRUE, nil
+		}
+		return object.FALSE, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: v.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("cannot convert %d to INTEGER: out of range", v.Uint())
+		}
+		return &object.Integer{Value: int64(v.Uint())}, nil
+	case reflect.Float32, reflect.Float64:
+		return &object.Float{Value: v.Float()}, nil
+	case reflect.String:
+		return &object.String{Value: v.String()}, nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return &object.Array{Elements: []object.Object{}}, nil
+		}
+
+		elements := make([]object.Object, v.Len())
+		for i := range elements {
+			element, err := toElement(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = element
+		}
+		return &object.Array{Elements: elements}, nil
+	case reflect.Map:
+		pairs := make(map[object.HashKey]object.HashPair)
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := toObject(iter.Key())
+			if err != nil {
+				return nil, err
+			}
+
+			hashable, ok := key.(object.Hashable)
+			if !ok {
+				return nil, fmt.Errorf("cannot convert %s to hash key", iter.Key().Type())
+			}
+
+			value, err := toElement(iter.Value())
+			if err != nil {
+				return nil, err
+			}
+			pairs[hashable.HashKey()] = object.HashPair{Key: key, Value: value}
+		}
+		return &object.Hash{Pairs: pairs}, nil
+	case reflect.Struct:
+		pairs := make(map[object.HashKey]object.HashPair)
+		for _, field := range structFields(v.Type()) {
+			value, err := toElement(v.FieldByIndex(field.index))
+			if err != nil {
+				return nil, err
+			}
+
+			key := &object.String{Value: field.name}
+			pairs[key.HashKey()] = object.HashPair{Key: key, Value: value}
+		}
+		return &object.Hash{Pairs: pairs}, nil
+	case reflect.Ptr, reflect.Interface:
+		return toObject(v.Elem())
+	case reflect.Func:
+		return wrapFunc(v)
+	}
+
+	return nil, fmt.Errorf("cannot convert %s to gonk object", v.Type())
+}
+
+// toElement converts v to an element of an array or hash, where nil is stored
+// as null since the engines expect an object.
+func toElement(v reflect.Value) (object.Object, error) {
+	obj, err := toObject(v)
+	if obj == nil && err == nil {
+		return object.NULL, nil
+	}
+	return obj, err
+}
+
+// wrapFunc turns a Go function into a builtin.
+func wrapFunc(fn reflect.Value) (object.Object, error) {
+	fnType := fn.Type()
+
+	numOut := fnType.NumOut()
+	returnsErr := numOut > 0 && fnType.Out(numOut-1) == errorType
+	if returnsErr {
+		numOut--
+	}
+	if numOut > 1 {
+		return nil, fmt.Errorf("cannot convert %s to gonk object: too many results", fnType)
+	}
+
+	builtin := func(host *object.Host, args ...object.Object) object.Object {
+		numIn := fnType.NumIn()
+		if fnType.IsVariadic() && len(args) < numIn-1 {
+			return &object.Error{Message: fmt.Sprintf("wrong number of arguments. got=%d, want at least %d", len(args), numIn-1)}
+		}
+		if !fnType.IsVariadic() && len(args) != numIn {
+			return &object.Error{Message: fmt.Sprintf("wrong number of arguments. got=%d, want=%d", len(args), numIn)}
+		}
+
+		in := make([]reflect.Value, len(args))
+		for i, arg := range args {
+			var argType reflect.Type
+			if fnType.IsVariadic() && i >= numIn-1 {
+				argType = fnType.In(numIn - 1).Elem()
+			} else {
+				argType = fnType.In(i)
+			}
+
+			in[i] = reflect.New(argType)
+			if err := fromObject(host, arg, in[i].Elem()); err != nil {
+				return &object.Error{Message: fmt.Sprintf("argument %d: %s", i+1, err)}
+			}
+			in[i] = in[i].Elem()
+		}
+
+		out := fn.Call(in)
+		if returnsErr {
+			if err, _ := out[len(out)-1].Interface().(error); err != nil {
+				return &object.Error{Message: err.Error()}
+			}
+		}
+		if numOut == 0 {
+			return nil
+		}
+
+		result, err := toObject(out[0])
+		if err != nil {
+			return &object.Error{Message: err.Error()}
+		}
+		return result
+	}
+
+	return &object.Builtin{Fn: builtin}, nil
+}
+
+// FromObject stores the Go form of obj in the value target points to, following
+// the mapping of ToObject. Into an interface{} integers become int64, floats
+// float64, arrays []interface{} and hashes map[string]interface{}, or
+// map[interface{}]interface{} if they have keys that are not strings. Only
+// builtins convert to Go functions, since other functions need an engine to
+// run, and other objects are stored as they are. Null converts to the zero
+// value. Builtins converted to Go functions use object.StdHost, see
+// Interpreter.FromObject for the IO of an interpreter.
+func FromObject(obj object.Object, target interface{}) error {
+	return fromObjectTo(object.StdHost, obj, target)
+}
+
+func fromObjectTo(host *object.Host, obj object.Object, target interface{}) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("target must be a non-nil pointer, got %T", target)
+	}
+	return fromObject(host, obj, v.Elem())
+}
+
+func fromObject(host *object.Host, obj object.Object, v reflect.Value) error {
+	if obj == nil || obj.Type() == object.NULL_OBJ {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	if v.Kind() == reflect.Interface && v.NumMethod() == 0 {
+		v.Set(reflect.ValueOf(naturalValue(obj)))
+		return nil
+	}
+
+	if reflect.TypeOf(obj).AssignableTo(v.Type()) {
+		v.Set(reflect.ValueOf(obj))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		ptr := reflect.New(v.Type().Elem())
+		if err := fromObject(host, obj, ptr.Elem()); err != nil {
+			return err
+		}
+		v.Set(ptr)
+		return nil
+	}
+
+	switch obj := obj.(type) {
+	case *object.Boolean:
+		if v.Kind() == reflect.Bool {
+			v.SetBool(obj.Value)
+			return nil
+		}
+	case *object.Integer:
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if v.OverflowInt(obj.Value) {
+				return fmt.Errorf("cannot convert %d to %s: out of range", obj.Value, v.Type())
+			}
+			v.SetInt(obj.Value)
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if obj.Value < 0 || v.OverflowUint(uint64(obj.Value)) {
+				return fmt.Errorf("cannot convert %d to %s: out of range", obj.Value, v.Type())
+			}
+			v.SetUint(uint64(obj.Value))
+			return nil
+		case reflect.Float32, reflect.Float64:
+			v.SetFloat(float64(obj.Value))
+			return nil
+		}
+	case *object.Float:
+		if v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64 {
+			v.SetFloat(obj.Value)
+			return nil
+		}
+	case *object.String:
+		if v.Kind() == reflect.String {
+			v.SetString(obj.Value)
+			return nil
+		}
+	case *object.Array:
+		return arrayFromObject(host, obj, v)
+	case *object.Hash:
+		return hashFromObject(host, obj, v)
+	case *object.Builtin:
+		if v.Kind() == reflect.Func {
+			v.Set(unwrapBuiltin(host, obj, v.Type()))
+			return nil
+		}
+	}
+
+	return fmt.Errorf("cannot convert %s to %s", obj.Type(), v.Type())
+}
+
+func arrayFromObject(host *object.Host, array *object.Array, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), len(array.Elements), len(array.Elements)))
+	case reflect.Array:
+		if v.Len() != len(array.Elements) {
+			return fmt.Errorf("cannot convert ARRAY of length %d to %s", len(array.Elements), v.Type())
+		}
+	default:
+		return fmt.Errorf("cannot convert %s to %s", array.Type(), v.Type())
+	}
+
+	for i, element := range array.Elements {
+		if err := fromObject(host, element, v.Index(i)); err != nil {
+			return fmt.Errorf("index %d: %s", i, err)
+		}
+	}
+	return nil
+}
+
+func hashFromObject(host *object.Host, hash *object.Hash, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Map:
+		m := reflect.MakeMapWithSize(v.Type(), len(hash.Pairs))
+		for _, pair := range hash.Pairs {
+			key := reflect.New(v.Type().Key()).Elem()
+			if err := fromObject(host, pair.Key, key); err != nil {
+				return fmt.Errorf("key %s: %s", pair.Key.Inspect(), err)
+			}
+
+			value := reflect.New(v.Type().Elem()).Elem()
+			if err := fromObject(host, pair.Value, value); err != nil {
+				return fmt.Errorf("key %s: %s", pair.Key.Inspect(), err)
+			}
+			m.SetMapIndex(key, value)
+		}
+		v.Set(m)
+		return nil
+	case reflect.Struct:
+		for _, field := range structFields(v.Type()) {
+			key := &object.String{Value: field.name}
+			pair, ok := hash.Pairs[key.HashKey()]
+			if !ok {
+				continue
+			}
+
+			if err := fromObject(host, pair.Value, v.FieldByIndex(field.index)); err != nil {
+				return fmt.Errorf("field %s: %s", field.name, err)
+			}
+		}
+		return nil
+	}
+
+	return fmt.Errorf("cannot convert %s to %s", hash.Type(), v.Type())
+}
+
+// unwrapBuiltin makes a Go function of type fnType that calls builtin with
+// host. If the call fails, the error is returned as last result when fnType
+// has one and panics otherwise.
+func unwrapBuiltin(host *object.Host, builtin *object.Builtin, fnType reflect.Type) reflect.Value {
+	return reflect.MakeFunc(fnType, func(in []reflect.Value) []reflect.Value {
+		out := make([]reflect.Value, fnType.NumOut())
+		for i := range out {
+			out[i] = reflect.Zero(fnType.Out(i))
+		}
+
+		err := callBuiltin(host, builtin, fnType, in, out)
+		if err != nil {
+			if len(out) == 0 || fnType.Out(len(out)-1) != errorType {
+				panic(err)
+			}
+			out[len(out)-1] = reflect.ValueOf(&err).Elem()
+		}
+		return out
+	})
+}
+
+func callBuiltin(host *object.Host, builtin *object.Builtin, fnType reflect.Type, in, out []reflect.Value) error {
+	var args []object.Object
+	for i, arg := range in {
+		values := []reflect.Value{arg}
+		if fnType.IsVariadic() && i == len(in)-1 {
+			values = values[:0]
+			for j := 0; j < arg.Len(); j++ {
+				values = append(values, arg.Index(j))
+			}
+		}
+
+		for _, value := range values {
+			obj, err := toObject(value)
+			if err != nil {
+				return err
+			}
+			args = append(args, obj)
+		}
+	}
+
+	result := builtin.Fn(host, args...)
+	if errObj, ok := result.(*object.Error); ok {
+		return errors.New(errObj.Message)
+	}
+
+	if len(out) == 0 || fnType.Out(0) == errorType {
+		return nil
+	}
+
+	value := reflect.New(fnType.Out(0)).Elem()
+	if err := fromObject(host, result, value); err != nil {
+		return err
+	}
+	out[0] = value
+	return nil
+}
+
+// naturalValue returns the Go value FromObject stores in an interface{}.
+func naturalValue(obj object.Object) interface{} {
+	switch obj := obj.(type) {
+	case *object.Boolean:
+		return obj.Value
+	case *object.Integer:
+		return obj.Value
+	case *object.Float:
+		return obj.Value
+	case *object.String:
+		return obj.Value
+	case *object.Array:
+		elements := make([]interface{}, len(obj.Elements))
+		for i, element := range obj.Elements {
+			elements[i] = naturalValue(element)
+		}
+		return elements
+	case *object.Hash:
+		values := make(map[interface{}]interface{}, len(obj.Pairs))
+		stringKeys := make(map[string]interface{}, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			key := naturalValue(pair.Key)
+			values[key] = naturalValue(pair.Value)
+			if str, ok := key.(string); ok && stringKeys != nil {
+				stringKeys[str] = values[key]
+			} else {
+				stringKeys = nil
+			}
+		}
+
+		if stringKeys == nil {
+			return values
+		}
+		return stringKeys
+	}
+
+	return obj
+}
+
+type structField struct {
+	name  string
+	index []int
+}
+
+// structFields returns the exported fields of a struct type with their gonk names.
+func structFields(t reflect.Type) []structField {
+	var fields []structField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name := field.Name
+		if tag, ok := field.Tag.Lookup("gonk"); ok {
+			if tag == "-" {
+				continue
+			}
+			name = tag
+		}
+		fields = append(fields, structField{name: name, index: field.Index})
+	}
+
+	return fields
+}
@@ -0,0 +1,332 @@
+package interpreter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Soj447/gonk/object"
+)
+
+type point struct {
+	X      int
+	Y      int    `gonk:"y"`
+	Label  string `gonk:"-"`
+	hidden int
+}
+
+func TestToObject(t *testing.T) {
+	tests := []struct {
+		input    interface{}
+		expected string
+	}{
+		{5, "5"},
+		{uint8(7), "7"},
+		{2.5, "2.5"},
+		{"hi", "hi"},
+		{true, "true"},
+		{[]int{1, 2}, "[1, 2]"},
+		{[2]string{"a", "b"}, "[a, b]"},
+		{[]int(nil), "[]"},
+		{map[string]int{"a": 1}, "{a: 1}"},
+		{map[int]bool{1: false}, "{1: false}"},
+		{struct{ N []int }{[]int{3}}, "{N: [3]}"},
+		{&struct{ N int }{4}, "{N: 4}"},
+		{&object.Integer{Value: 9}, "9"},
+	}
+
+	for _, tt := range tests {
+		obj, err := ToObject(tt.input)
+		if err != nil {
+			t.Errorf("ToObject(%#v) failed: %s", tt.input, err)
+			continue
+		}
+
+		if obj.Inspect() != tt.expected {
+			t.Errorf("ToObject(%#v) wrong. want=%q, got=%q", tt.input, tt.expected, obj.Inspect())
+		}
+	}
+
+	if obj, err := ToObject((*point)(nil)); obj != nil || err != nil {
+		t.Errorf("ToObject of nil pointer should be nil. got=%v, %v", obj, err)
+	}
+}
+
+func TestConvertedValuesInScripts(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"if (f) { 1 } else { 2 }", "2"},
+		{"[t == true, f == false, !f, !t]", "[true, true, true, false]"},
+		{`if (isEven(3)) { "even" } else { "odd" }`, "odd"},
+		{"isEven(2) == true", "true"},
+		{`[h["a"] == 1, h["a"] == null(), h["b"][1]]`, "[false, true, null]"},
+	}
+
+	values := map[string]interface{}{
+		"t":      true,
+		"f":      false,
+		"isEven": func(n int) bool { return n%2 == 0 },
+		"h":      map[string]interface{}{"a": nil, "b": []interface{}{1, nil}},
+	}
+
+	for _, engine := range engines {
+		interp := newInterpreter(t, engine)
+		for name, value := range values {
+			interp.Set(name, mustToObject(t, value))
+		}
+		if _, err := interp.Eval(context.Background(), "let null = fn() { if (false) { 1 } };"); err != nil {
+			t.Fatalf("[%s] unexpected error: %s", engine, err)
+		}
+
+		for _, tt := range tests {
+			result, err := interp.Eval(context.Background(), tt.input)
+			if err != nil {
+				t.Errorf("[%s] unexpected error for %q: %s", engine, tt.input, err)
+				continue
+			}
+
+			if result.Inspect() != tt.expected {
+				t.Errorf("[%s] wrong result for %q. want=%q, got=%q", engine, tt.input, tt.expected, result.Inspect())
+			}
+		}
+	}
+}
+
+func TestToObjectErrors(t *testing.T) {
+	tests := []struct {
+		input    interface{}
+		expected string
+	}{
+		{make(chan int), "cannot convert chan int to gonk object"},
+		{uint64(math.MaxUint64), "cannot convert 18446744073709551615 to INTEGER: out of range"},
+		{map[[2]int]int{{1, 2}: 3}, "cannot convert [2]int to hash key"},
+		{[]interface{}{1, struct{ C chan int }{}}, "cannot convert chan int to gonk object"},
+		{func() (int, int) { return 1, 2 }, "cannot convert func() (int, int) to gonk object: too many results"},
+	}
+
+	for _, tt := range tests {
+		_, err := ToObject(tt.input)
+		if err == nil {
+			t.Errorf("expected error for %T", tt.input)
+			continue
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("wrong error for %T. want=%q, got=%q", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestFromObject(t *testing.T) {
+	var i int
+	testFromObject(t, &object.Integer{Value: 3}, &i, 3)
+
+	var f float64
+	testFromObject(t, &object.Integer{Value: 3}, &f, 3.0)
+
+	var s []string
+	testFromObject(t, mustToObject(t, []string{"a", "b"}), &s, []string{"a", "b"})
+
+	var m map[string]int
+	testFromObject(t, mustToObject(t, map[string]int{"a": 1, "b": 2}), &m, map[string]int{"a": 1, "b": 2})
+
+	var p point
+	testFromObject(t, mustToObject(t, map[string]interface{}{"X": 1, "y": 2, "Label": "l"}), &p, point{X: 1, Y: 2})
+
+	var ptr *int
+	testFromObject(t, &object.Integer{Value: 4}, &ptr, func() *int { n := 4; return &n }())
+
+	var natural interface{}
+	hash := mustToObject(t, map[string]interface{}{"list": []interface{}{1, "two", 3.5, false}})
+	testFromObject(t, hash, &natural, map[string]interface{}{"list": []interface{}{int64(1), "two", 3.5, false}})
+
+	var obj object.Object
+	testFromObject(t, &object.Integer{Value: 5}, &obj, object.Object(&object.Integer{Value: 5}))
+
+	i = 10
+	testFromObject(t, nil, &i, 0)
+}
+
+func TestFromObjectErrors(t *testing.T) {
+	var i int
+	var u uint8
+	var s string
+	var arr [3]int
+	var p point
+	var fn func() int
+
+	tests := []struct {
+		obj      object.Object
+		target   interface{}
+		expected string
+	}{
+		{&object.Integer{Value: 1}, i, "target must be a non-nil pointer, got int"},
+		{&object.String{Value: "a"}, &i, "cannot convert STRING to int"},
+		{&object.Integer{Value: 256}, &u, "cannot convert 256 to uint8: out of range"},
+		{&object.Integer{Value: -1}, &u, "cannot convert -1 to uint8: out of range"},
+		{&object.Boolean{Value: true}, &s, "cannot convert BOOLEAN to string"},
+		{mustToObject(t, []int{1, 2}), &arr, "cannot convert ARRAY of length 2 to [3]int"},
+		{mustToObject(t, []interface{}{1, "b"}), &[]int{}, "index 1: cannot convert STRING to int"},
+		{mustToObject(t, map[string]string{"X": "a"}), &p, "field X: cannot convert STRING to int"},
+		{&object.Function{}, &fn, "cannot convert FUNCTION to func() int"},
+	}
+
+	for _, tt := range tests {
+		err := FromObject(tt.obj, tt.target)
+		if err == nil {
+			t.Errorf("expected error converting %s to %T", tt.obj.Inspect(), tt.target)
+			continue
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("wrong error. want=%q, got=%q", tt.expected, err)
+		}
+	}
+}
+
+func TestFuncConversion(t *testing.T) {
+	divide := func(a, b int) (int, error) {
+		if b == 0 {
+			return 0, errors.New("division by zero")
+		}
+		return a / b, nil
+	}
+
+	for _, engine := range engines {
+		interp := newInterpreter(t, engine)
+		for name, fn := range map[string]interface{}{
+			"repeat": strings.Repeat,
+			"join":   func(sep string, parts ...string) string { return strings.Join(parts, sep) },
+			"divide": divide,
+		} {
+			obj, err := ToObject(fn)
+			if err != nil {
+				t.Fatalf("ToObject(%s) failed: %s", name, err)
+			}
+			interp.Set(name, obj)
+		}
+
+		tests := []struct {
+			input    string
+			expected string
+		}{
+			{`repeat("ab", 2)`, "abab"},
+			{`join("-", "a", "b", "c")`, "a-b-c"},
+			{`join(",")`, ""},
+			{`divide(7, 2)`, "3"},
+		}
+
+		for _, tt := range tests {
+			result, err := interp.Eval(context.Background(), tt.input)
+			if err != nil {
+				t.Fatalf("[%s] unexpected error for %q: %s", engine, tt.input, err)
+			}
+
+			if result.Inspect() != tt.expected {
+				t.Errorf("[%s] wrong result for %q. want=%q, got=%q", engine, tt.input, tt.expected, result.Inspect())
+			}
+		}
+
+		errorTests := []struct {
+			input    string
+			expected string
+		}{
+			{`divide(1, 0)`, "division by zero"},
+			{`repeat("ab")`, "wrong number of arguments. got=1, want=2"},
+			{`join()`, "wrong number of arguments. got=0, want at least 1"},
+			{`repeat(1, 2)`, "argument 1: cannot convert INTEGER to string"},
+		}
+
+		for _, tt := range errorTests {
+			_, err := interp.Eval(context.Background(), tt.input)
+
+			var runtimeErr *RuntimeError
+			if !errors.As(err, &runtimeErr) {
+				t.Errorf("[%s] expected *RuntimeError for %q. got=%T (%v)", engine, tt.input, err, err)
+				continue
+			}
+
+			if runtimeErr.Err.Error() != tt.expected {
+				t.Errorf("[%s] wrong error for %q. want=%q, got=%q", engine, tt.input, tt.expected, runtimeErr.Err)
+			}
+		}
+	}
+}
+
+func TestBuiltinToFunc(t *testing.T) {
+	var length func(string) (int, error)
+	if err := FromObject(object.Builtins[0].Builtin, &length); err != nil {
+		t.Fatalf("FromObject failed: %s", err)
+	}
+
+	n, err := length("héllo")
+	if err != nil || n != 5 {
+		t.Errorf("length(\"héllo\") wrong. want=5, got=%d, %v", n, err)
+	}
+
+	_, err = length("")
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	var lengthOf func(int) (int, error)
+	if err := FromObject(object.Builtins[0].Builtin, &lengthOf); err != nil {
+		t.Fatalf("FromObject failed: %s", err)
+	}
+
+	_, err = lengthOf(1)
+	if err == nil || err.Error() != "argument to `len` not supported. got INTEGER" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+}
+
+func TestBuiltinToFuncHost(t *testing.T) {
+	for _, engine := range engines {
+		interp := newInterpreter(t, engine)
+
+		var out bytes.Buffer
+		interp.SetIO(strings.NewReader(""), &out)
+
+		var puts func(...interface{})
+		if err := interp.FromObject(object.GetBuiltinByName("puts"), &puts); err != nil {
+			t.Fatalf("[%s] FromObject failed: %s", engine, err)
+		}
+		puts("direct")
+
+		apply := mustToObject(t, func(f func(string)) { f("from script") })
+		interp.Set("apply", apply)
+		if _, err := interp.Eval(context.Background(), "apply(puts)"); err != nil {
+			t.Fatalf("[%s] unexpected error: %s", engine, err)
+		}
+
+		if out.String() != "direct\nfrom script\n" {
+			t.Errorf("[%s] wrong output. got=%q", engine, out.String())
+		}
+	}
+}
+
+func mustToObject(t *testing.T, v interface{}) object.Object {
+	obj, err := ToObject(v)
+	if err != nil {
+		t.Fatalf("ToObject(%#v) failed: %s", v, err)
+	}
+	return obj
+}
+
+func testFromObject(t *testing.T, obj object.Object, target interface{}, expected interface{}) {
+	if err := FromObject(obj, target); err != nil {
+		t.Errorf("FromObject into %T failed: %s", target, err)
+		return
+	}
+
+	got := reflect.ValueOf(target).Elem().Interface()
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("FromObject into %T wrong. want=%#v, got=%#v", target, expected, got)
+	}
+}
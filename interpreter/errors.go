@@ -0,0 +1,44 @@
+package interpreter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Soj447/gonk/token"
+)
+
+// ParseError is returned when the source does not parse. Errors holds every
+// parser error as "line:col: message".
+type ParseError struct {
+	Errors []string
+}
+
+func (e *ParseError) Error() string {
+	return strings.Join(e.Errors, "\n")
+}
+
+// CompileError is returned when macro expansion or the compilation to
+// bytecode fails.
+type CompileError struct {
+	Err error
+}
+
+func (e *CompileError) Error() string { return e.Err.Error() }
+
+func (e *CompileError) Unwrap() error { return e.Err }
+
+// RuntimeError is returned when the program fails while running, with the
+// position of the failing expression when it is known.
+type RuntimeError struct {
+	Pos token.Position
+	Err error
+}
+
+func (e *RuntimeError) Error() string {
+	if e.Pos.IsValid() {
+		return fmt.Sprintf("%s: %s", e.Pos, e.Err)
+	}
+	return e.Err.Error()
+}
+
+func (e *RuntimeError) Unwrap() error { return e.Err }
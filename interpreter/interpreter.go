@@ -0,0 +1,184 @@
+// Package interpreter runs gonk source code from Go programs. An Interpreter
+// keeps its globals and macros between calls to Eval, like a REPL session.
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/Soj447/gonk/ast"
+	"github.com/Soj447/gonk/compiler"
+	"github.com/Soj447/gonk/evaluator"
+	"github.com/Soj447/gonk/lexer"
+	"github.com/Soj447/gonk/object"
+	"github.com/Soj447/gonk/parser"
+	"github.com/Soj447/gonk/vm"
+)
+
+const (
+	ENGINE_EVAL = "eval"
+	ENGINE_VM   = "vm"
+)
+
+type Interpreter struct {
+	engine string
+	limits object.Limits
+	host   *object.Host
+
+	// evaluator state
+	env *object.Environment
+
+	// macros defined so far, expanded before either engine runs a source
+	macroEnv *object.Environment
+
+	// compiler and VM state kept alive across sources
+	symbolTable *compiler.SymbolTable
+	constants   []object.Object
+	globals     []object.Object
+}
+
+// New creates an interpreter that runs sources with the tree-walking
+// evaluator (ENGINE_EVAL) or compiles them for the VM (ENGINE_VM).
+func New(engine string) (*Interpreter, error) {
+	if engine != ENGINE_EVAL && engine != ENGINE_VM {
+		return nil, fmt.Errorf("unknown engine %q", engine)
+	}
+
+	i := &Interpreter{
+		engine:      engine,
+		env:         object.NewEnvironment(),
+		macroEnv:    object.NewEnvironment(),
+		symbolTable: compiler.NewSymbolTable(),
+		constants:   []object.Object{},
+		globals:     make([]object.Object, vm.GlobalSize),
+		host:        object.StdHost,
+	}
+	for idx, v := range object.Builtins {
+		i.symbolTable.DefineBuiltin(idx, v.Name)
+	}
+
+	return i, nil
+}
+
+// Eval runs source and returns the value of its last statement, or nil when
+// that statement is not an expression or a return. Errors are
+// *ParseError, *CompileError or *RuntimeError. A run stopped because ctx is
+// done or a limit is exceeded returns a *RuntimeError wrapping ctx.Err() or an
+// *object.StepLimitError, *object.CallDepthError or *object.AllocLimitError.
+func (i *Interpreter) Eval(ctx context.Context, source string) (object.Object, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, &RuntimeError{Err: err}
+	}
+
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &ParseError{Errors: p.Errors()}
+	}
+
+	evaluator.DefineMacros(program, i.macroEnv)
+	if err := evaluator.ExpandMacros(program, i.macroEnv); err != nil {
+		return nil, &CompileError{Err: err}
+	}
+
+	run := i.runEval
+	if i.engine == ENGINE_VM {
+		run = i.runVM
+	}
+
+	result, err := run(ctx, program)
+	if err != nil || !program.HasResult() {
+		// any other statement leaves behind whatever the engine evaluated last
+		return nil, err
+	}
+	return result, nil
+}
+
+func (i *Interpreter) runEval(ctx context.Context, program *ast.Program) (object.Object, error) {
+	result := evaluator.EvalContext(ctx, program, i.env, i.limits)
+	if errObj, ok := result.(*object.Error); ok {
+		return nil, runtimeError(errObj)
+	}
+
+	return result, nil
+}
+
+func (i *Interpreter) runVM(ctx context.Context, program *ast.Program) (object.Object, error) {
+	// compile against a copy so a failed compilation leaves no half-defined globals behind
+	symbolTable := i.symbolTable.Copy()
+
+	comp := compiler.NewWithState(symbolTable, i.constants)
+	if err := comp.Compile(program); err != nil {
+		return nil, &CompileError{Err: err}
+	}
+
+	bytecode := comp.ByteCode()
+	i.symbolTable = symbolTable
+	i.constants = bytecode.Constants
+
+	machine := vm.NewWithGlobalsStore(bytecode, i.globals)
+	machine.SetHost(i.host)
+	if err := machine.RunContext(ctx, i.limits); err != nil {
+		var runtimeErr *vm.RuntimeError
+		if errors.As(err, &runtimeErr) {
+			return nil, &RuntimeError{Pos: runtimeErr.Pos, Err: runtimeErr.Err}
+		}
+		return nil, &RuntimeError{Err: err}
+	}
+
+	return machine.LastPoppedStackElem(), nil
+}
+
+func runtimeError(errObj *object.Error) *RuntimeError {
+	if errObj.Err != nil {
+		return &RuntimeError{Pos: errObj.Pos, Err: errObj.Err}
+	}
+	return &RuntimeError{Pos: errObj.Pos, Err: errors.New(errObj.Message)}
+}
+
+// SetLimits bounds the resources of the sources run from now on.
+func (i *Interpreter) SetLimits(limits object.Limits) {
+	i.limits = limits
+}
+
+// SetIO makes builtins like puts and gets use out and in instead of the
+// standard output and input.
+func (i *Interpreter) SetIO(in io.Reader, out io.Writer) {
+	i.host = object.NewHost(in, out)
+	i.env.SetHost(i.host)
+}
+
+// Set binds a global name to value, replacing any previous binding.
+func (i *Interpreter) Set(name string, value object.Object) {
+	if i.engine == ENGINE_VM {
+		sym := i.symbolTable.Define(name)
+		i.globals[sym.Index] = value
+		return
+	}
+	i.env.Set(name, value)
+}
+
+// Get returns the value bound to a global name.
+func (i *Interpreter) Get(name string) (object.Object, bool) {
+	if i.engine == ENGINE_VM {
+		sym, ok := i.symbolTable.Resolve(name)
+		if !ok || sym.Scope != compiler.GlobalScope || i.globals[sym.Index] == nil {
+			return nil, false
+		}
+		return i.globals[sym.Index], true
+	}
+	return i.env.Get(name)
+}
+
+// Register makes fn callable from scripts under name.
+func (i *Interpreter) Register(name string, fn object.BuiltinFunction) {
+	i.Set(name, &object.Builtin{Fn: fn})
+}
+
+// FromObject is like the package level FromObject, but builtins converted to
+// Go functions read and write through the IO set with SetIO.
+func (i *Interpreter) FromObject(obj object.Object, target interface{}) error {
+	return fromObjectTo(i.host, obj, target)
+}
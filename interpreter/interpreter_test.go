@@ -0,0 +1,206 @@
+package interpreter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Soj447/gonk/object"
+)
+
+var engines = []string{ENGINE_EVAL, ENGINE_VM}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		inputs   []string
+		expected int64
+	}{
+		{[]string{"1 + 2"}, 3},
+		{[]string{"let a = 5;", "let double = fn(x) { x * 2 };", "double(a)"}, 10},
+		{[]string{"let m = macro(x) { quote(unquote(x) + 1) };", "m(41)"}, 42},
+	}
+
+	for _, engine := range engines {
+		for _, tt := range tests {
+			interp := newInterpreter(t, engine)
+
+			var result object.Object
+			for _, input := range tt.inputs {
+				var err error
+				result, err = interp.Eval(context.Background(), input)
+				if err != nil {
+					t.Fatalf("[%s] unexpected error for %q: %s", engine, input, err)
+				}
+			}
+
+			testInteger(t, engine, result, tt.expected)
+		}
+	}
+}
+
+func TestEvalStatementsWithoutResult(t *testing.T) {
+	inputs := []string{"let x = 5;", "x; let y = x;", "while (false) { }", "let f = fn() { 1 };"}
+
+	for _, engine := range engines {
+		interp := newInterpreter(t, engine)
+		for _, input := range inputs {
+			result, err := interp.Eval(context.Background(), input)
+			if err != nil {
+				t.Fatalf("[%s] unexpected error for %q: %s", engine, input, err)
+			}
+			if result != nil {
+				t.Errorf("[%s] expected no result for %q. got=%s", engine, input, result.Inspect())
+			}
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	for _, engine := range engines {
+		interp := newInterpreter(t, engine)
+
+		_, err := interp.Eval(context.Background(), "let = 1;")
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("[%s] expected *ParseError. got=%T (%v)", engine, err, err)
+		} else if parseErr.Errors[0] != "1:5: expected next token to be IDENT, got = instead" {
+			t.Errorf("[%s] wrong parse error. got=%q", engine, parseErr.Errors[0])
+		}
+
+		_, err = interp.Eval(context.Background(), "let f = fn() { 1 + true };\nf()")
+		var runtimeErr *RuntimeError
+		if !errors.As(err, &runtimeErr) {
+			t.Errorf("[%s] expected *RuntimeError. got=%T (%v)", engine, err, err)
+		} else if runtimeErr.Pos.Line != 1 || runtimeErr.Pos.Column != 18 {
+			t.Errorf("[%s] wrong runtime error position. got=%s", engine, runtimeErr.Pos)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err = interp.Eval(ctx, "1")
+		if !errors.As(err, &runtimeErr) || !errors.Is(err, context.Canceled) {
+			t.Errorf("[%s] expected *RuntimeError wrapping context.Canceled. got=%T (%v)", engine, err, err)
+		}
+	}
+
+	interp := newInterpreter(t, ENGINE_VM)
+	_, err := interp.Eval(context.Background(), "x")
+	var compileErr *CompileError
+	if !errors.As(err, &compileErr) {
+		t.Errorf("expected *CompileError. got=%T (%v)", err, err)
+	}
+
+	if _, err := New("jit"); err == nil {
+		t.Errorf("expected error for unknown engine")
+	}
+}
+
+func TestLimits(t *testing.T) {
+	for _, engine := range engines {
+		interp := newInterpreter(t, engine)
+		interp.SetLimits(object.Limits{MaxSteps: 10000})
+
+		_, err := interp.Eval(context.Background(), "let i = 0;\nwhile (true) { i = i + 1; }")
+		var stepErr *object.StepLimitError
+		if !errors.As(err, &stepErr) {
+			t.Errorf("[%s] expected *object.StepLimitError. got=%T (%v)", engine, err, err)
+		}
+
+		var runtimeErr *RuntimeError
+		if !errors.As(err, &runtimeErr) || runtimeErr.Pos.Line != 2 {
+			t.Errorf("[%s] expected *RuntimeError on line 2. got=%v", engine, err)
+		}
+
+		i, ok := interp.Get("i")
+		if !ok || i.(*object.Integer).Value == 0 {
+			t.Errorf("[%s] loop did not run before the limit. got=%v", engine, i)
+		}
+	}
+}
+
+func TestSetIO(t *testing.T) {
+	for _, engine := range engines {
+		interp := newInterpreter(t, engine)
+
+		var out bytes.Buffer
+		interp.SetIO(strings.NewReader("ada\nlovelace"), &out)
+
+		input := `let first = gets(); let last = gets(); puts("${first} ${last}", gets())`
+		if _, err := interp.Eval(context.Background(), input); err != nil {
+			t.Fatalf("[%s] unexpected error: %s", engine, err)
+		}
+
+		if out.String() != "ada lovelace\nnull\n" {
+			t.Errorf("[%s] wrong output. got=%q", engine, out.String())
+		}
+	}
+}
+
+func TestSetGet(t *testing.T) {
+	for _, engine := range engines {
+		interp := newInterpreter(t, engine)
+		interp.Set("base", &object.Integer{Value: 40})
+
+		if _, err := interp.Eval(context.Background(), "let answer = base + 2;"); err != nil {
+			t.Fatalf("[%s] unexpected error: %s", engine, err)
+		}
+
+		answer, ok := interp.Get("answer")
+		if !ok {
+			t.Fatalf("[%s] answer is not defined", engine)
+		}
+		testInteger(t, engine, answer, 42)
+
+		if _, ok := interp.Get("missing"); ok {
+			t.Errorf("[%s] missing is defined", engine)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	for _, engine := range engines {
+		interp := newInterpreter(t, engine)
+
+		var calls int
+		interp.Register("sum", func(host *object.Host, args ...object.Object) object.Object {
+			calls++
+			var sum int64
+			for _, arg := range args {
+				sum += arg.(*object.Integer).Value
+			}
+			return &object.Integer{Value: sum}
+		})
+
+		result, err := interp.Eval(context.Background(), "sum(1, 2, 3) + sum()")
+		if err != nil {
+			t.Fatalf("[%s] unexpected error: %s", engine, err)
+		}
+
+		testInteger(t, engine, result, 6)
+		if calls != 2 {
+			t.Errorf("[%s] sum called %d times, want 2", engine, calls)
+		}
+	}
+}
+
+func newInterpreter(t *testing.T, engine string) *Interpreter {
+	interp, err := New(engine)
+	if err != nil {
+		t.Fatalf("could not create interpreter: %s", err)
+	}
+	return interp
+}
+
+func testInteger(t *testing.T, engine string, obj object.Object, expected int64) {
+	integer, ok := obj.(*object.Integer)
+	if !ok {
+		t.Errorf("[%s] object is not Integer. got=%T (%+v)", engine, obj, obj)
+		return
+	}
+
+	if integer.Value != expected {
+		t.Errorf("[%s] object has wrong value. got=%d, want=%d", engine, integer.Value, expected)
+	}
+}
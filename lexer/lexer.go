@@ -1,35 +1,76 @@
 package lexer
 
-import "github.com/Soj447/gonk/token"
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Soj447/gonk/token"
+)
 
 type Lexer struct {
 	input        string
-	position     int  // current position in input(points to current char)
-	readPosition int  // current reading position in input (after current char)
-	ch           byte // current char under examination
+	position     int  // current byte offset in input (points to current char)
+	readPosition int  // current reading byte offset in input (after current char)
+	ch           rune // current char under examination
+
+	file   string
+	line   int // line of the current char, starting at 1
+	column int // column of the current char, starting at 1
+
+	templates []template // strings with an open ${ expression, innermost last
+}
+
+// template tracks a string literal while the lexer is inside one of its ${ } expressions.
+type template struct {
+	start  int            // byte offset of the opening quote
+	pos    token.Position // position of the opening quote
+	braces int            // braces opened inside the expression and not closed yet
 }
 
 func New(input string) *Lexer {
-	lexer := &Lexer{input: input}
+	return NewFile("", input)
+}
+
+// NewFile creates a lexer whose token positions refer to the given file name.
+func NewFile(file, input string) *Lexer {
+	lexer := &Lexer{input: input, file: file, line: 1}
 	lexer.readChar()
 	return lexer
 }
 
 func (lexer *Lexer) readChar() {
+	if lexer.ch == '\n' {
+		lexer.line++
+		lexer.column = 0
+	}
+	lexer.column++
+
+	width := 1
 	// check if we hit the end
 	if lexer.readPosition >= len(lexer.input) {
 		lexer.ch = 0
 	} else {
-		lexer.ch = lexer.input[lexer.readPosition]
+		lexer.ch, width = utf8.DecodeRuneInString(lexer.input[lexer.readPosition:])
 	}
 	lexer.position = lexer.readPosition
-	lexer.readPosition += 1
+	lexer.readPosition += width
 }
 
 func (lexer *Lexer) NextToken() token.Token {
 	var tok token.Token
 
-	lexer.skipWhitespace()
+	comments, ok := lexer.skipTrivia()
+	if !ok {
+		// the last comment is not terminated and runs until the end of the input
+		last := comments[len(comments)-1]
+		tok = token.Token{Type: token.ILLEGAL, Literal: last.Text, Pos: last.Pos}
+		tok.Comments = comments[:len(comments)-1]
+		return tok
+	}
+	tok.Comments = comments
+	pos := lexer.currentPosition()
 
 	switch lexer.ch {
 	case '=':
@@ -47,8 +88,21 @@ func (lexer *Lexer) NextToken() token.Token {
 	case ')':
 		tok = newToken(token.RPAREN, lexer.ch)
 	case '{':
+		if n := len(lexer.templates); n > 0 {
+			lexer.templates[n-1].braces++
+		}
 		tok = newToken(token.LBRACE, lexer.ch)
 	case '}':
+		n := len(lexer.templates)
+		if n > 0 && lexer.templates[n-1].braces == 0 {
+			// end of a ${ } expression, the string goes on after it
+			t := lexer.templates[n-1]
+			lexer.templates = lexer.templates[:n-1]
+			return lexer.finishString(lexer.readString(t, pos, true), comments)
+		}
+		if n > 0 {
+			lexer.templates[n-1].braces--
+		}
 		tok = newToken(token.RBRACE, lexer.ch)
 	case '[':
 		tok = newToken(token.LBRACKET, lexer.ch)
@@ -56,6 +110,8 @@ func (lexer *Lexer) NextToken() token.Token {
 		tok = newToken(token.RBRACKET, lexer.ch)
 	case ',':
 		tok = newToken(token.COMMA, lexer.ch)
+	case '.':
+		tok = newToken(token.DOT, lexer.ch)
 	case '+':
 		tok = newToken(token.PLUS, lexer.ch)
 	case '-':
@@ -72,13 +128,43 @@ func (lexer *Lexer) NextToken() token.Token {
 		tok = newToken(token.SLASH, lexer.ch)
 	case '*':
 		tok = newToken(token.ASTERISK, lexer.ch)
+	case '%':
+		tok = newToken(token.PERCENT, lexer.ch)
 	case '<':
-		tok = newToken(token.LT, lexer.ch)
+		if lexer.peekChar() == '=' {
+			ch := lexer.ch
+			lexer.readChar()
+			tok = token.Token{Type: token.LT_EQ, Literal: string(ch) + string(lexer.ch)}
+		} else {
+			tok = newToken(token.LT, lexer.ch)
+		}
 	case '>':
-		tok = newToken(token.GT, lexer.ch)
+		if lexer.peekChar() == '=' {
+			ch := lexer.ch
+			lexer.readChar()
+			tok = token.Token{Type: token.GT_EQ, Literal: string(ch) + string(lexer.ch)}
+		} else {
+			tok = newToken(token.GT, lexer.ch)
+		}
+	case '&':
+		if lexer.peekChar() == '&' {
+			ch := lexer.ch
+			lexer.readChar()
+			tok = token.Token{Type: token.AND, Literal: string(ch) + string(lexer.ch)}
+		} else {
+			tok = newToken(token.ILLEGAL, lexer.ch)
+		}
+	case '|':
+		if lexer.peekChar() == '|' {
+			ch := lexer.ch
+			lexer.readChar()
+			tok = token.Token{Type: token.OR, Literal: string(ch) + string(lexer.ch)}
+		} else {
+			tok = newToken(token.ILLEGAL, lexer.ch)
+		}
 	case '"':
-		tok.Type = token.STRING
-		tok.Literal = lexer.readString()
+		t := template{start: lexer.position, pos: pos}
+		return lexer.finishString(lexer.readString(t, pos, false), comments)
 	case ':':
 		tok = newToken(token.COLON, lexer.ch)
 	case 0:
@@ -88,10 +174,11 @@ func (lexer *Lexer) NextToken() token.Token {
 		if isLetter(lexer.ch) {
 			tok.Literal = lexer.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Pos = pos
 			return tok
 		} else if isDigit(lexer.ch) {
-			tok.Type = token.INT
-			tok.Literal = lexer.readNumber()
+			tok.Type, tok.Literal = lexer.readNumber()
+			tok.Pos = pos
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, lexer.ch)
@@ -99,18 +186,25 @@ func (lexer *Lexer) NextToken() token.Token {
 	}
 
 	lexer.readChar()
+	tok.Pos = pos
+	tok.Comments = comments
 	return tok
 }
 
-func newToken(tokenType token.TokenType, ch byte) token.Token {
+func (lexer *Lexer) currentPosition() token.Position {
+	return token.Position{File: lexer.file, Line: lexer.line, Column: lexer.column}
+}
+
+func newToken(tokenType token.TokenType, ch rune) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
 
-func (lexer *Lexer) peekChar() byte {
+func (lexer *Lexer) peekChar() rune {
 	if lexer.readPosition >= len(lexer.input) {
 		return 0
 	} else {
-		return lexer.input[lexer.readPosition]
+		ch, _ := utf8.DecodeRuneInString(lexer.input[lexer.readPosition:])
+		return ch
 	}
 }
 
@@ -122,8 +216,8 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[position:l.position]
 }
 
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_' || ch == '!' || ch == '?'
+func isLetter(ch rune) bool {
+	return unicode.IsLetter(ch) || ch == '_' || ch == '!' || ch == '?'
 }
 
 func (lexer *Lexer) skipWhitespace() {
@@ -132,23 +226,179 @@ func (lexer *Lexer) skipWhitespace() {
 	}
 }
 
-func (lexer *Lexer) readString() string {
+// skipTrivia skips whitespace and comments and returns the comments. It
+// reports false if the last comment is a block comment without its closing */.
+func (lexer *Lexer) skipTrivia() ([]token.Comment, bool) {
+	var comments []token.Comment
+
+	for {
+		lexer.skipWhitespace()
+		if lexer.ch != '/' || (lexer.peekChar() != '/' && lexer.peekChar() != '*') {
+			return comments, true
+		}
+
+		pos := lexer.currentPosition()
+		position := lexer.position
+		terminated := true
+		if lexer.peekChar() == '/' {
+			for lexer.ch != '\n' && lexer.ch != 0 {
+				lexer.readChar()
+			}
+		} else {
+			terminated = lexer.skipBlockComment()
+		}
+
+		text := lexer.input[position:lexer.position]
+		comments = append(comments, token.Comment{Text: text, Pos: pos})
+		if !terminated {
+			return comments, false
+		}
+	}
+}
+
+// skipBlockComment skips a /* */ comment, which does not nest.
+func (lexer *Lexer) skipBlockComment() bool {
+	lexer.readChar()
+	lexer.readChar()
+	for lexer.ch != 0 {
+		if lexer.ch == '*' && lexer.peekChar() == '/' {
+			lexer.readChar()
+			lexer.readChar()
+			return true
+		}
+		lexer.readChar()
+	}
+	return false
+}
+
+// readString reads a string literal, or its part after a ${ } expression if
+// resumed is set, and resolves its escape sequences. The part ends at the
+// closing quote or at the next ${, which is returned as TEMPLATE_START or
+// TEMPLATE_MIDDLE token. If the string is not terminated or has an invalid
+// escape, it returns an ILLEGAL token with the offending source text.
+func (lexer *Lexer) readString(t template, pos token.Position, resumed bool) token.Token {
+	var out strings.Builder
+	var illegal *token.Token
+
+	for {
+		lexer.readChar()
+
+		switch {
+		case lexer.ch == 0:
+			return token.Token{Type: token.ILLEGAL, Literal: lexer.input[t.start:lexer.position], Pos: t.pos}
+		case lexer.ch == '"':
+			if illegal != nil {
+				return *illegal
+			}
+			if resumed {
+				return token.Token{Type: token.TEMPLATE_END, Literal: out.String(), Pos: pos}
+			}
+			return token.Token{Type: token.STRING, Literal: out.String(), Pos: pos}
+		case lexer.ch == '$' && lexer.peekChar() == '{':
+			lexer.readChar()
+			lexer.templates = append(lexer.templates, template{start: t.start, pos: t.pos})
+			if illegal != nil {
+				return *illegal
+			}
+			if resumed {
+				return token.Token{Type: token.TEMPLATE_MIDDLE, Literal: out.String(), Pos: pos}
+			}
+			return token.Token{Type: token.TEMPLATE_START, Literal: out.String(), Pos: pos}
+		case lexer.ch == '\\':
+			escapeStart, escapePos := lexer.position, lexer.currentPosition()
+
+			ch, ok := lexer.readEscape()
+			if !ok && illegal == nil {
+				// keep reading to the closing quote, so lexing goes on after the string
+				literal := lexer.input[escapeStart:lexer.readPosition]
+				illegal = &token.Token{Type: token.ILLEGAL, Literal: literal, Pos: escapePos}
+			}
+			out.WriteRune(ch)
+		default:
+			out.WriteRune(lexer.ch)
+		}
+	}
+}
+
+// finishString steps over the char ending a string part, which is the closing
+// quote or the brace of ${, unless the input ended.
+func (lexer *Lexer) finishString(tok token.Token, comments []token.Comment) token.Token {
+	if lexer.ch != 0 {
+		lexer.readChar()
+	}
+	tok.Comments = comments
+	return tok
+}
+
+var escapes = map[rune]rune{
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+	'0':  0,
+	'"':  '"',
+	'$':  '$',
+	'\\': '\\',
+}
+
+// readEscape reads the escape sequence after a backslash. It stops on the
+// last char of the sequence, or before a quote ending the string.
+func (lexer *Lexer) readEscape() (rune, bool) {
+	if lexer.peekChar() == 0 {
+		return 0, false
+	}
 	lexer.readChar()
-	position := lexer.position
-	for lexer.ch != '"' {
+
+	if ch, ok := escapes[lexer.ch]; ok {
+		return ch, true
+	}
+	if lexer.ch != 'u' || lexer.peekChar() != '{' {
+		return 0, false
+	}
+
+	// \u{XXXX} with 1 to 6 hex digits
+	lexer.readChar()
+	start := lexer.readPosition
+	for isHexDigit(lexer.peekChar()) {
 		lexer.readChar()
 	}
-	return lexer.input[position:lexer.position]
+	digits := lexer.input[start:lexer.readPosition]
+	if lexer.peekChar() != '}' || len(digits) == 0 || len(digits) > 6 {
+		return 0, false
+	}
+	lexer.readChar()
+
+	code, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil || !utf8.ValidRune(rune(code)) {
+		return 0, false
+	}
+	return rune(code), true
 }
 
-func (lexer *Lexer) readNumber() string {
+// readNumber reads an integer or, if the digits are followed by a dot and
+// another digit, a float literal.
+func (lexer *Lexer) readNumber() (token.TokenType, string) {
+	var tokenType token.TokenType = token.INT
+
 	position := lexer.position
 	for isDigit(lexer.ch) {
 		lexer.readChar()
 	}
-	return lexer.input[position:lexer.position]
+
+	if lexer.ch == '.' && isDigit(lexer.peekChar()) {
+		tokenType = token.FLOAT
+		lexer.readChar()
+		for isDigit(lexer.ch) {
+			lexer.readChar()
+		}
+	}
+
+	return tokenType, lexer.input[position:lexer.position]
 }
 
-func isDigit(ch byte) bool {
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
+
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
@@ -13,7 +13,7 @@ let add = fn(x, y) {
     x + y;
 };
 let result = add(five, ten);
-!/*5;
+!/ *5;
 5 < 10 > 5;
 if (5 < 10) {
     return true;
@@ -25,6 +25,9 @@ if (5 < 10) {
 "foobar"
 "foo bar"
 [1:,2]
+while for in break continue macro
+3.14 10. import as
+a <= b >= c % d && e || f & |
 `
 
 	tests := []struct {
@@ -111,6 +114,30 @@ if (5 < 10) {
 		{token.COMMA, ","},
 		{token.INT, "2"},
 		{token.RBRACKET, "]"},
+		{token.WHILE, "while"},
+		{token.FOR, "for"},
+		{token.IN, "in"},
+		{token.BREAK, "break"},
+		{token.CONTINUE, "continue"},
+		{token.MACRO, "macro"},
+		{token.FLOAT, "3.14"},
+		{token.INT, "10"},
+		{token.DOT, "."},
+		{token.IMPORT, "import"},
+		{token.AS, "as"},
+		{token.IDENT, "a"},
+		{token.LT_EQ, "<="},
+		{token.IDENT, "b"},
+		{token.GT_EQ, ">="},
+		{token.IDENT, "c"},
+		{token.PERCENT, "%"},
+		{token.IDENT, "d"},
+		{token.AND, "&&"},
+		{token.IDENT, "e"},
+		{token.OR, "||"},
+		{token.IDENT, "f"},
+		{token.ILLEGAL, "&"},
+		{token.ILLEGAL, "|"},
 		{token.EOF, ""},
 	}
 
@@ -129,3 +156,257 @@ if (5 < 10) {
 		}
 	}
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := `let x = 5;
+  x + "ab";
+`
+
+	tests := []struct {
+		expectedType token.TokenType
+		expectedLine int
+		expectedCol  int
+	}{
+		{token.LET, 1, 1},
+		{token.IDENT, 1, 5},
+		{token.ASSIGN, 1, 7},
+		{token.INT, 1, 9},
+		{token.SEMICOLON, 1, 10},
+		{token.IDENT, 2, 3},
+		{token.PLUS, 2, 5},
+		{token.STRING, 2, 7},
+		{token.SEMICOLON, 2, 11},
+		{token.EOF, 3, 1},
+	}
+
+	lexer := NewFile("main.gonk", input)
+	for i, tt := range tests {
+		tok := lexer.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Pos.Line != tt.expectedLine || tok.Pos.Column != tt.expectedCol {
+			t.Fatalf("tests[%d] - position wrong. expected=%d:%d, got=%d:%d",
+				i, tt.expectedLine, tt.expectedCol, tok.Pos.Line, tok.Pos.Column)
+		}
+
+		if tok.Pos.File != "main.gonk" {
+			t.Fatalf("tests[%d] - file wrong. expected=%q, got=%q",
+				i, "main.gonk", tok.Pos.File)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// leading
+let x = 5; // trailing
+/* block
+   comment */ x /**/ / 2
+// at the end`
+
+	tests := []struct {
+		expectedType     token.TokenType
+		expectedLiteral  string
+		expectedComments []token.Comment
+	}{
+		{token.LET, "let", []token.Comment{{Text: "// leading", Pos: token.Position{Line: 1, Column: 1}}}},
+		{token.IDENT, "x", nil},
+		{token.ASSIGN, "=", nil},
+		{token.INT, "5", nil},
+		{token.SEMICOLON, ";", nil},
+		{token.IDENT, "x", []token.Comment{
+			{Text: "// trailing", Pos: token.Position{Line: 2, Column: 12}},
+			{Text: "/* block\n   comment */", Pos: token.Position{Line: 3, Column: 1}},
+		}},
+		{token.SLASH, "/", []token.Comment{{Text: "/**/", Pos: token.Position{Line: 4, Column: 17}}}},
+		{token.INT, "2", nil},
+		{token.EOF, "", []token.Comment{{Text: "// at the end", Pos: token.Position{Line: 5, Column: 1}}}},
+	}
+
+	lexer := New(input)
+	for i, tt := range tests {
+		tok := lexer.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if len(tok.Comments) != len(tt.expectedComments) {
+			t.Fatalf("tests[%d] - wrong number of comments. expected=%d, got=%d (%+v)",
+				i, len(tt.expectedComments), len(tok.Comments), tok.Comments)
+		}
+
+		for j, comment := range tt.expectedComments {
+			if tok.Comments[j] != comment {
+				t.Fatalf("tests[%d] - comment %d wrong. expected=%+v, got=%+v",
+					i, j, comment, tok.Comments[j])
+			}
+		}
+	}
+}
+
+func TestUnterminatedBlockComment(t *testing.T) {
+	lexer := New("x /* never closed")
+
+	tok := lexer.NextToken()
+	if tok.Type != token.IDENT {
+		t.Fatalf("first token wrong. expected=%q, got=%q", token.IDENT, tok.Type)
+	}
+
+	tok = lexer.NextToken()
+	if tok.Type != token.ILLEGAL || tok.Literal != "/* never closed" {
+		t.Fatalf("expected ILLEGAL token for the comment. got=%q %q", tok.Type, tok.Literal)
+	}
+
+	tok = lexer.NextToken()
+	if tok.Type != token.EOF {
+		t.Fatalf("last token wrong. expected=%q, got=%q", token.EOF, tok.Type)
+	}
+}
+
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"plain"`, "plain"},
+		{`"a\"b"`, `a"b`},
+		{`"tab\there\nnext"`, "tab\there\nnext"},
+		{`"back\\slash\r\0"`, "back\\slash\r\x00"},
+		{`"\u{48}\u{e9}\u{1F600}"`, "Hé😀"},
+		{`"grüße"`, "grüße"},
+		{"\"two\nlines\"", "two\nlines"},
+	}
+
+	for _, tt := range tests {
+		tok := New(tt.input).NextToken()
+
+		if tok.Type != token.STRING {
+			t.Fatalf("%s - tokentype wrong. expected=%q, got=%q (%q)", tt.input, token.STRING, tok.Type, tok.Literal)
+		}
+
+		if tok.Literal != tt.expected {
+			t.Errorf("%s - literal wrong. expected=%q, got=%q", tt.input, tt.expected, tok.Literal)
+		}
+	}
+}
+
+func TestIllegalStrings(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedLiteral string
+		expectedColumn  int
+	}{
+		{`"abc`, `"abc`, 1},
+		{`x "a\"`, `"a\"`, 3},
+		{`"a\qb"`, `\q`, 3},
+		{`"\u{}"`, `\u{`, 2},
+		{`"\u{1234567}"`, `\u{1234567`, 2},
+		{`"\u{D800}"`, `\u{D800}`, 2},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+		if tok.Type != token.ILLEGAL {
+			tok = l.NextToken()
+		}
+
+		if tok.Type != token.ILLEGAL {
+			t.Fatalf("%s - tokentype wrong. expected=%q, got=%q", tt.input, token.ILLEGAL, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Errorf("%s - literal wrong. expected=%q, got=%q", tt.input, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Pos.Column != tt.expectedColumn {
+			t.Errorf("%s - column wrong. expected=%d, got=%d", tt.input, tt.expectedColumn, tok.Pos.Column)
+		}
+
+		if next := l.NextToken(); next.Type != token.EOF {
+			t.Errorf("%s - expected EOF after the string. got=%q", tt.input, next.Type)
+		}
+	}
+}
+
+func TestUnicodeIdentifiers(t *testing.T) {
+	input := `let größe = "ü"; größe`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedCol     int
+	}{
+		{token.LET, "let", 1},
+		{token.IDENT, "größe", 5},
+		{token.ASSIGN, "=", 11},
+		{token.STRING, "ü", 13},
+		{token.SEMICOLON, ";", 16},
+		{token.IDENT, "größe", 18},
+		{token.EOF, "", 23},
+	}
+
+	lexer := New(input)
+	for i, tt := range tests {
+		tok := lexer.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - token wrong. expected=%q %q, got=%q %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+
+		if tok.Pos.Column != tt.expectedCol {
+			t.Fatalf("tests[%d] - column wrong. expected=%d, got=%d", i, tt.expectedCol, tok.Pos.Column)
+		}
+	}
+}
+
+func TestTemplateStrings(t *testing.T) {
+	input := `"a ${x} b ${ {"k": "${y}"}["k"] }\${c}"`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedCol     int
+	}{
+		{token.TEMPLATE_START, "a ", 1},
+		{token.IDENT, "x", 6},
+		{token.TEMPLATE_MIDDLE, " b ", 7},
+		{token.LBRACE, "{", 14},
+		{token.STRING, "k", 15},
+		{token.COLON, ":", 18},
+		{token.TEMPLATE_START, "", 20},
+		{token.IDENT, "y", 23},
+		{token.TEMPLATE_END, "", 24},
+		{token.RBRACE, "}", 26},
+		{token.LBRACKET, "[", 27},
+		{token.STRING, "k", 28},
+		{token.RBRACKET, "]", 31},
+		{token.TEMPLATE_END, "${c}", 33},
+		{token.EOF, "", 40},
+	}
+
+	lexer := New(input)
+	for i, tt := range tests {
+		tok := lexer.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - token wrong. expected=%q %q, got=%q %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+
+		if tok.Pos.Column != tt.expectedCol {
+			t.Fatalf("tests[%d] - column wrong. expected=%d, got=%d", i, tt.expectedCol, tok.Pos.Column)
+		}
+	}
+}
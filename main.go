@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"os/user"
@@ -23,11 +24,36 @@ const WELCOME_TEXT = `
 `
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "run":
+			os.Exit(runCommand(os.Args[2:], os.Stderr))
+		case "compile":
+			os.Exit(compileCommand(os.Args[2:], os.Stderr))
+		case "exec":
+			os.Exit(execCommand(os.Args[2:], os.Stderr))
+		case "disasm":
+			os.Exit(disasmCommand(os.Args[2:], os.Stdout, os.Stderr))
+		}
+	}
+
+	engine := flag.String("engine", repl.ENGINE_EVAL, "execution backend of the REPL: eval or vm")
+	flag.Parse()
+
+	if *engine != repl.ENGINE_EVAL && *engine != repl.ENGINE_VM {
+		fmt.Fprintf(os.Stderr, "unknown engine %q\n", *engine)
+		os.Exit(exitUsage)
+	}
+
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
 	}
 
 	fmt.Printf(WELCOME_TEXT, user.Username)
-	repl.Start(os.Stdin, os.Stdout)
+	if *engine == repl.ENGINE_VM {
+		repl.StartVM(os.Stdin, os.Stdout)
+	} else {
+		repl.Start(os.Stdin, os.Stdout)
+	}
 }
@@ -0,0 +1,31 @@
+package object
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+)
+
+// Host is the outside world of a running program as builtins see it.
+type Host struct {
+	Stdin  *bufio.Reader
+	Stdout io.Writer
+}
+
+func NewHost(stdin io.Reader, stdout io.Writer) *Host {
+	return &Host{Stdin: bufio.NewReader(stdin), Stdout: stdout}
+}
+
+// StdHost is used by programs run without a host of their own.
+var StdHost = NewHost(os.Stdin, os.Stdout)
+
+// ReadLine returns the next line of input without its line ending. ok is false
+// once the input is exhausted.
+func (h *Host) ReadLine() (line string, ok bool) {
+	line, err := h.Stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", false
+	}
+	return strings.TrimRight(line, "\r\n"), true
+}
@@ -0,0 +1,69 @@
+package object
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Iterator walks over the elements of an array, the keys of a hash or the
+// characters of a string. It is shared by the evaluator and the VM so for-in
+// loops visit elements in the same order in both.
+type Iterator struct {
+	next func() (Object, bool)
+}
+
+func (it *Iterator) Type() ObjectType { return ITERATOR_OBJ }
+func (it *Iterator) Inspect() string  { return "iterator" }
+
+// Next returns the next element and false once the iterator is exhausted.
+func (it *Iterator) Next() (Object, bool) {
+	return it.next()
+}
+
+func NewIterator(obj Object) (*Iterator, error) {
+	switch obj := obj.(type) {
+	case *Array:
+		i := 0
+		return &Iterator{next: func() (Object, bool) {
+			if i >= len(obj.Elements) {
+				return nil, false
+			}
+			i++
+			return obj.Elements[i-1], true
+		}}, nil
+	case *Hash:
+		keys := make([]Object, 0, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			keys = append(keys, pair.Key)
+		}
+		// hash pairs have no order, sort the keys to make iteration deterministic
+		sort.Slice(keys, func(i, j int) bool {
+			if keys[i].Type() != keys[j].Type() {
+				return keys[i].Type() < keys[j].Type()
+			}
+			return keys[i].Inspect() < keys[j].Inspect()
+		})
+
+		i := 0
+		return &Iterator{next: func() (Object, bool) {
+			if i >= len(keys) {
+				return nil, false
+			}
+			i++
+			return keys[i-1], true
+		}}, nil
+	case *String:
+		chars := []rune(obj.Value)
+
+		i := 0
+		return &Iterator{next: func() (Object, bool) {
+			if i >= len(chars) {
+				return nil, false
+			}
+			i++
+			return &String{Value: string(chars[i-1])}, true
+		}}, nil
+	default:
+		return nil, fmt.Errorf("type %s is not iterable", obj.Type())
+	}
+}
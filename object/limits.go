@@ -0,0 +1,117 @@
+package object
+
+import (
+	"context"
+	"fmt"
+)
+
+// Limits bounds the resources a program may use. Zero fields are unlimited.
+type Limits struct {
+	// MaxSteps is the number of instructions the VM, or nodes the evaluator, may run.
+	MaxSteps int64
+	// MaxCallDepth is the number of nested function calls.
+	MaxCallDepth int
+	// MaxAllocSize is the number of elements of an array or bytes of a string.
+	MaxAllocSize int
+}
+
+type StepLimitError struct {
+	Max int64
+}
+
+func (e *StepLimitError) Error() string {
+	return fmt.Sprintf("step limit of %d exceeded", e.Max)
+}
+
+type CallDepthError struct {
+	Max int
+}
+
+func (e *CallDepthError) Error() string {
+	return fmt.Sprintf("call depth limit of %d exceeded", e.Max)
+}
+
+type AllocLimitError struct {
+	Size int
+	Max  int
+}
+
+func (e *AllocLimitError) Error() string {
+	return fmt.Sprintf("allocation of %d exceeds limit of %d", e.Size, e.Max)
+}
+
+// how many steps run between two checks of the context
+const cancelCheckInterval = 1024
+
+// Monitor enforces Limits and the cancellation of a context while a program
+// runs. A nil *Monitor enforces nothing.
+type Monitor struct {
+	ctx    context.Context
+	limits Limits
+
+	steps int64
+	depth int
+}
+
+func NewMonitor(ctx context.Context, limits Limits) *Monitor {
+	return &Monitor{ctx: ctx, limits: limits}
+}
+
+// Step counts one step. It fails once the step limit is exceeded or, checked
+// every few steps, the context is done.
+func (m *Monitor) Step() error {
+	if m == nil {
+		return nil
+	}
+
+	m.steps++
+	if m.limits.MaxSteps > 0 && m.steps > m.limits.MaxSteps {
+		return &StepLimitError{Max: m.limits.MaxSteps}
+	}
+
+	if m.steps%cancelCheckInterval == 0 {
+		return m.ctx.Err()
+	}
+	return nil
+}
+
+// Enter counts a function call until the matching Leave.
+func (m *Monitor) Enter() error {
+	if m == nil {
+		return nil
+	}
+
+	if m.limits.MaxCallDepth > 0 && m.depth >= m.limits.MaxCallDepth {
+		return &CallDepthError{Max: m.limits.MaxCallDepth}
+	}
+	m.depth++
+	return nil
+}
+
+func (m *Monitor) Leave() {
+	if m != nil {
+		m.depth--
+	}
+}
+
+// Alloc fails if obj is a string or an array larger than the allocation limit.
+func (m *Monitor) Alloc(obj Object) error {
+	if m == nil || m.limits.MaxAllocSize <= 0 {
+		return nil
+	}
+
+	var size int
+	switch obj := obj.(type) {
+	case *String:
+		size = len(obj.Value)
+	case *Array:
+		size = len(obj.Elements)
+	default:
+		return nil
+	}
+
+	if size > m.limits.MaxAllocSize {
+		return &AllocLimitError{Size: size, Max: m.limits.MaxAllocSize}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package object
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMonitor(t *testing.T) {
+	var unlimited *Monitor
+	if unlimited.Step() != nil || unlimited.Enter() != nil || unlimited.Alloc(&String{Value: "abc"}) != nil {
+		t.Errorf("nil monitor enforced a limit")
+	}
+	unlimited.Leave()
+
+	m := NewMonitor(context.Background(), Limits{MaxSteps: 2, MaxCallDepth: 1, MaxAllocSize: 2})
+
+	if m.Step() != nil || m.Step() != nil {
+		t.Fatalf("step limit exceeded too early")
+	}
+	if err, ok := m.Step().(*StepLimitError); !ok || err.Max != 2 {
+		t.Errorf("expected *StepLimitError with Max 2. got=%v", err)
+	}
+
+	if m.Enter() != nil {
+		t.Fatalf("call depth limit exceeded too early")
+	}
+	if _, ok := m.Enter().(*CallDepthError); !ok {
+		t.Errorf("expected *CallDepthError")
+	}
+	m.Leave()
+	if m.Enter() != nil {
+		t.Errorf("call depth not released by Leave")
+	}
+
+	if m.Alloc(&Array{Elements: []Object{&Null{}, &Null{}}}) != nil || m.Alloc(&Integer{Value: 100}) != nil {
+		t.Errorf("allocation limit exceeded too early")
+	}
+	if err, ok := m.Alloc(&String{Value: "abc"}).(*AllocLimitError); !ok || err.Size != 3 {
+		t.Errorf("expected *AllocLimitError with Size 3. got=%v", err)
+	}
+}
+
+func TestMonitorCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := NewMonitor(ctx, Limits{})
+	cancel()
+
+	var err error
+	for i := 0; i < cancelCheckInterval && err == nil; i++ {
+		err = m.Step()
+	}
+
+	if err != context.Canceled {
+		t.Errorf("expected context.Canceled within %d steps. got=%v", cancelCheckInterval, err)
+	}
+}
@@ -4,9 +4,14 @@ import (
 	"bytes"
 	"fmt"
 	"hash/fnv"
+	"math"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/Soj447/gonk/ast"
+	"github.com/Soj447/gonk/code"
+	"github.com/Soj447/gonk/token"
 )
 
 type ObjectType string
@@ -16,10 +21,13 @@ type Object interface {
 	Inspect() string
 }
 
-type BuiltinFunction func(args ...Object) Object
+// BuiltinFunction is a function implemented in Go. It reads input and writes
+// output through host.
+type BuiltinFunction func(host *Host, args ...Object) Object
 
 const (
 	INTEGER_OBJ      = "INTEGER"
+	FLOAT_OBJ        = "FLOAT"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	STRING_OBJ       = "STRING"
 	NULL_OBJ         = "NULL"
@@ -29,6 +37,16 @@ const (
 	BUILTIN_OBJ      = "BUILTIN"
 	ARRAY_OBJ        = "ARRAY"
 	HASH_OBJ         = "HASH"
+	ITERATOR_OBJ     = "ITERATOR"
+	BREAK_OBJ        = "BREAK"
+	CONTINUE_OBJ     = "CONTINUE"
+	QUOTE_OBJ        = "QUOTE"
+	MACRO_OBJ        = "MACRO"
+	MODULE_OBJ       = "MODULE"
+
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
+	CLOSURE_OBJ           = "CLOSURE"
+	CELL_OBJ              = "CELL"
 )
 
 type Integer struct {
@@ -38,6 +56,21 @@ type Integer struct {
 func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
 func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
 
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+
+// Inspect always keeps a decimal point or an exponent, so 2.0 does not look like an integer.
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if strings.ContainsAny(s, ".eIN") {
+		return s
+	}
+	return s + ".0"
+}
+
 type Boolean struct {
 	Value bool
 }
@@ -57,6 +90,14 @@ type Null struct{}
 func (n *Null) Type() ObjectType { return NULL_OBJ }
 func (n *Null) Inspect() string  { return "null" }
 
+// TRUE, FALSE and NULL are the only booleans and null of a program, the
+// engines compare them by identity.
+var (
+	TRUE  = &Boolean{Value: true}
+	FALSE = &Boolean{Value: false}
+	NULL  = &Null{}
+)
+
 type ReturnValue struct {
 	Value Object
 }
@@ -64,29 +105,108 @@ type ReturnValue struct {
 func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
 func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
 
+// Break and Continue signal loop control flow through the evaluator, like ReturnValue does for functions.
+type Break struct{}
+
+func (b *Break) Type() ObjectType { return BREAK_OBJ }
+func (b *Break) Inspect() string  { return "break" }
+
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType { return CONTINUE_OBJ }
+func (c *Continue) Inspect() string  { return "continue" }
+
 type Error struct {
 	Message string
+	Pos     token.Position // where the error was raised, if known
+	Err     error          // the Go error behind Message, e.g. a *StepLimitError
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
-func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
+func (e *Error) Inspect() string {
+	if e.Pos.IsValid() {
+		return "ERROR: " + e.Pos.String() + ": " + e.Message
+	}
+	return "ERROR: " + e.Message
+}
 
 type Environment struct {
 	store map[string]Object
 	outer *Environment
+
+	program *program
+}
+
+// program is the state shared by all environments of a program.
+type program struct {
+	// modules imported so far by path
+	modules map[string]*Module
+
+	// limits of the current run, nil when there are none
+	monitor *Monitor
+
+	// host the builtins use, nil for StdHost
+	host *Host
 }
 
 func NewEnvironment() *Environment {
 	s := make(map[string]Object)
-	return &Environment{store: s, outer: nil}
+	p := &program{modules: make(map[string]*Module)}
+	return &Environment{store: s, outer: nil, program: p}
 }
 
 func NewEnclosedEnvironment(outer *Environment) *Environment {
 	env := NewEnvironment()
 	env.outer = outer
+	env.program = outer.program
+	return env
+}
+
+// NewModuleEnvironment creates the top-level environment of a module imported
+// from importer. It shares no bindings with importer, only the program state.
+func NewModuleEnvironment(importer *Environment) *Environment {
+	env := NewEnvironment()
+	env.program = importer.program
 	return env
 }
 
+// Module returns the module imported from path. A nil module with true means
+// the module is still being loaded.
+func (e *Environment) Module(path string) (*Module, bool) {
+	module, ok := e.program.modules[path]
+	return module, ok
+}
+
+func (e *Environment) SetModule(path string, module *Module) {
+	e.program.modules[path] = module
+}
+
+// DropModule forgets a module that failed to load so it can be imported again.
+func (e *Environment) DropModule(path string) {
+	delete(e.program.modules, path)
+}
+
+// Monitor returns the monitor of the current run, nil if it has no limits.
+func (e *Environment) Monitor() *Monitor {
+	return e.program.monitor
+}
+
+func (e *Environment) SetMonitor(m *Monitor) {
+	e.program.monitor = m
+}
+
+// Host returns the host builtins called from this environment use.
+func (e *Environment) Host() *Host {
+	if e.program.host == nil {
+		return StdHost
+	}
+	return e.program.host
+}
+
+func (e *Environment) SetHost(h *Host) {
+	e.program.host = h
+}
+
 func (e *Environment) Get(name string) (Object, bool) {
 	obj, ok := e.store[name]
 	if !ok && e.outer != nil {
@@ -100,6 +220,31 @@ func (e *Environment) Set(name string, val Object) Object {
 	return val
 }
 
+// Assign rebinds name in the innermost environment that defines it. It
+// reports false if the name is not defined at all.
+func (e *Environment) Assign(name string, val Object) (Object, bool) {
+	if _, ok := e.store[name]; ok {
+		e.store[name] = val
+		return val, true
+	}
+
+	if e.outer != nil {
+		return e.outer.Assign(name, val)
+	}
+
+	return nil, false
+}
+
+// Names returns the names bound directly in this environment, without the outer ones.
+func (e *Environment) Names() []string {
+	names := make([]string, 0, len(e.store))
+	for name := range e.store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 type Function struct {
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
@@ -125,6 +270,39 @@ func (f *Function) Inspect() string {
 	return out.String()
 }
 
+// Quote holds an unevaluated AST node, created by quote(...) and consumed by macros.
+type Quote struct {
+	Node ast.Node
+}
+
+func (q *Quote) Type() ObjectType { return QUOTE_OBJ }
+func (q *Quote) Inspect() string  { return "QUOTE(" + q.Node.String() + ")" }
+
+type Macro struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (m *Macro) Type() ObjectType { return MACRO_OBJ }
+func (m *Macro) Inspect() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range m.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString("macro")
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") {\n")
+	out.WriteString(m.Body.String())
+	out.WriteString("\n}")
+
+	return out.String()
+}
+
 type Builtin struct {
 	Fn BuiltinFunction
 }
@@ -182,6 +360,15 @@ func (h *Hash) Inspect() string {
 	return out.String()
 }
 
+// Module holds the top-level bindings of an imported file by name.
+type Module struct {
+	Name    string
+	Members map[string]Object
+}
+
+func (m *Module) Type() ObjectType { return MODULE_OBJ }
+func (m *Module) Inspect() string  { return fmt.Sprintf("module(%q)", m.Name) }
+
 type Hashable interface {
 	HashKey() HashKey
 }
@@ -190,6 +377,10 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+func (f *Float) HashKey() HashKey {
+	return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
+}
+
 func (b *Boolean) HashKey() HashKey {
 	var value uint64
 
@@ -208,4 +399,40 @@ func (s *String) HashKey() HashKey {
 	h.Write([]byte(s.Value))
 
 	return HashKey{Type: s.Type(), Value: h.Sum64()}
-}
\ No newline at end of file
+}
+
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	NumLocals     int
+	NumParameters int
+	// SourceMap maps instruction offsets to the source position they were compiled from
+	SourceMap map[int]token.Position
+}
+
+func (cf *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION_OBJ }
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", cf)
+}
+
+type Closure struct {
+	Fn *CompiledFunction
+	// Free holds the cells of the captured variables
+	Free []Object
+}
+
+func (c *Closure) Type() ObjectType { return CLOSURE_OBJ }
+func (c *Closure) Inspect() string {
+	return fmt.Sprintf("Closure[%p]", c)
+}
+
+// Cell holds a variable captured by closures, so they share it with the
+// function defining it. The VM keeps cells in local slots and Closure.Free,
+// they are never values of the program.
+type Cell struct {
+	Value Object
+}
+
+func (c *Cell) Type() ObjectType { return CELL_OBJ }
+func (c *Cell) Inspect() string {
+	return fmt.Sprintf("Cell[%p]", c)
+}
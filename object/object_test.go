@@ -21,3 +21,40 @@ func TestStringHashKey(t *testing.T) {
 	}
 
 }
+
+func TestFloatHashKey(t *testing.T) {
+	one1 := &Float{Value: 1.5}
+	one2 := &Float{Value: 1.5}
+	two := &Float{Value: 2.5}
+
+	if one1.HashKey() != one2.HashKey() {
+		t.Errorf("floats with same value have different hash keys")
+	}
+
+	if one1.HashKey() == two.HashKey() {
+		t.Errorf("floats with different values have same hash keys")
+	}
+
+	if (&Float{Value: 1}).HashKey() == (&Integer{Value: 1}).HashKey() {
+		t.Errorf("float and integer have same hash keys")
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{1.5, "1.5"},
+		{2, "2.0"},
+		{-0.25, "-0.25"},
+		{1e21, "1e+21"},
+	}
+
+	for _, tt := range tests {
+		got := (&Float{Value: tt.value}).Inspect()
+		if got != tt.expected {
+			t.Errorf("wrong Inspect for %g. want=%q, got=%q", tt.value, tt.expected, got)
+		}
+	}
+}
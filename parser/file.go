@@ -0,0 +1,39 @@
+package parser
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Soj447/gonk/ast"
+	"github.com/Soj447/gonk/lexer"
+	"github.com/Soj447/gonk/token"
+)
+
+// ParseFile reads and parses the source file at path. Positions in the
+// program and in the returned error refer to path.
+func ParseFile(path string) (*ast.Program, error) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	p := New(lexer.NewFile(path, string(source)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, errors.New(strings.Join(p.Errors(), "\n"))
+	}
+
+	return program, nil
+}
+
+// ResolveImport returns the absolute path of an imported file. Relative paths
+// are resolved against the directory of the importing file or, if it is not
+// known, the working directory.
+func ResolveImport(path string, from token.Position) (string, error) {
+	if !filepath.IsAbs(path) && from.File != "" {
+		path = filepath.Join(filepath.Dir(from.File), path)
+	}
+	return filepath.Abs(path)
+}
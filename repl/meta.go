@@ -0,0 +1,159 @@
+package repl
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Soj447/gonk/compiler"
+	"github.com/Soj447/gonk/lexer"
+	"github.com/Soj447/gonk/object"
+	"github.com/Soj447/gonk/parser"
+	"github.com/Soj447/gonk/token"
+)
+
+type metaCommand struct {
+	description string
+	run         func(s *session, arg string)
+}
+
+var metaCommands map[string]metaCommand
+
+func init() {
+	// assigned in init because :help refers to the map itself
+	metaCommands = map[string]metaCommand{
+		"tokens":   {"print the tokens of the input", (*session).printTokens},
+		"ast":      {"print the parsed program of the input", (*session).printAst},
+		"bytecode": {"print the compiled instructions and constants of the input", (*session).printBytecode},
+		"env":      {"print the bindings of the environment", (*session).printEnv},
+		"reset":    {"clear the environment", (*session).reset},
+		"help":     {"print this help", (*session).printHelp},
+	}
+}
+
+func isMetaCommand(input string) bool {
+	return strings.HasPrefix(strings.TrimSpace(input), ":")
+}
+
+// runMetaCommand executes `:name [input]`. Commands inspecting code work on
+// the given input or, if it is omitted, on the last evaluated one.
+func (s *session) runMetaCommand(input string) {
+	input = strings.TrimPrefix(strings.TrimSpace(input), ":")
+	name, arg := input, ""
+	if i := strings.IndexAny(input, " \t\n"); i >= 0 {
+		name, arg = input[:i], strings.TrimSpace(input[i:])
+	}
+
+	cmd, ok := metaCommands[name]
+	if !ok {
+		fmt.Fprintf(s.out, "unknown command :%s, see :help\n", name)
+		return
+	}
+
+	if arg == "" {
+		arg = s.lastInput
+	}
+	cmd.run(s, arg)
+}
+
+func (s *session) printTokens(input string) {
+	l := lexer.New(input)
+	for tok := l.NextToken(); ; tok = l.NextToken() {
+		for _, comment := range tok.Comments {
+			fmt.Fprintf(s.out, "%s\t%-10s %q\n", comment.Pos, "COMMENT", comment.Text)
+		}
+		if tok.Type == token.EOF {
+			break
+		}
+		fmt.Fprintf(s.out, "%s\t%-10s %q\n", tok.Pos, tok.Type, tok.Literal)
+	}
+}
+
+func (s *session) printAst(input string) {
+	p := parser.New(lexer.New(input))
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParserErrors(s.out, p.Errors())
+		return
+	}
+
+	for _, stmt := range program.Statements {
+		fmt.Fprintf(s.out, "%T\t%s\n", stmt, stmt.String())
+	}
+}
+
+func (s *session) printBytecode(input string) {
+	p := parser.New(lexer.New(input))
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParserErrors(s.out, p.Errors())
+		return
+	}
+
+	comp := compiler.New()
+	if s.engine == ENGINE_VM {
+		// resolve the session globals without defining new ones in the session
+		comp = compiler.NewWithState(s.symbolTable.Copy(), s.constants)
+	}
+
+	if err := comp.Compile(program); err != nil {
+		fmt.Fprintf(s.out, "compilation failed: %s\n", err)
+		return
+	}
+
+	bytecode := comp.ByteCode()
+	fmt.Fprintf(s.out, "instructions:\n%s", bytecode.Instructions)
+
+	fmt.Fprintf(s.out, "constants:\n")
+	for i, constant := range bytecode.Constants {
+		switch constant := constant.(type) {
+		case *object.CompiledFunction:
+			fmt.Fprintf(s.out, "%04d %s\n%s", i, constant.Type(), constant.Instructions)
+		default:
+			fmt.Fprintf(s.out, "%04d %s %s\n", i, constant.Type(), constant.Inspect())
+		}
+	}
+}
+
+func (s *session) printEnv(string) {
+	if s.engine == ENGINE_VM {
+		for _, sym := range s.symbolTable.Symbols() {
+			// names starting with $ are hidden variables of the compiler
+			if sym.Scope != compiler.GlobalScope || strings.HasPrefix(sym.Name, "$") {
+				continue
+			}
+
+			value := s.globals[sym.Index]
+			if value == nil {
+				continue
+			}
+			fmt.Fprintf(s.out, "%s = %s\n", sym.Name, value.Inspect())
+		}
+		return
+	}
+
+	for _, name := range s.env.Names() {
+		value, _ := s.env.Get(name)
+		fmt.Fprintf(s.out, "%s = %s\n", name, value.Inspect())
+	}
+}
+
+func (s *session) reset(string) {
+	s.initState()
+	s.lastInput = ""
+	fmt.Fprintf(s.out, "environment cleared\n")
+}
+
+func (s *session) printHelp(string) {
+	names := make([]string, 0, len(metaCommands))
+	for name := range metaCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(s.out, ":%-9s %s\n", name, metaCommands[name].description)
+	}
+}
@@ -1,47 +1,195 @@
 package repl
 
 import (
-	"bufio"
 	"fmt"
 	"io"
+	"strings"
 
+	"github.com/Soj447/gonk/ast"
+	"github.com/Soj447/gonk/compiler"
 	"github.com/Soj447/gonk/evaluator"
 	"github.com/Soj447/gonk/lexer"
 	"github.com/Soj447/gonk/object"
 	"github.com/Soj447/gonk/parser"
+	"github.com/Soj447/gonk/token"
+	"github.com/Soj447/gonk/vm"
 )
 
 const PS1 = "[In]> "
+const PS2 = "  ... "
 
+const (
+	ENGINE_EVAL = "eval"
+	ENGINE_VM   = "vm"
+)
+
+type session struct {
+	out       io.Writer
+	engine    string
+	lastInput string
+
+	// reads the REPL input, so gets in a program reads the lines that follow it
+	host *object.Host
+
+	// evaluator state
+	env *object.Environment
+
+	// macros defined so far, expanded before either engine runs an input
+	macroEnv *object.Environment
+
+	// compiler and VM state kept alive across inputs
+	symbolTable *compiler.SymbolTable
+	constants   []object.Object
+	globals     []object.Object
+}
+
+// Start runs a REPL backed by the tree-walking evaluator.
 func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+	start(newSession(in, out, ENGINE_EVAL))
+}
 
+// StartVM runs a REPL that compiles every input and executes it in the VM.
+func StartVM(in io.Reader, out io.Writer) {
+	start(newSession(in, out, ENGINE_VM))
+}
+
+func start(s *session) {
 	for {
-		fmt.Printf(PS1)
-		scanned := scanner.Scan()
-		if !scanned {
+		io.WriteString(s.out, PS1)
+		input, ok := readInput(s.host)
+		if !ok {
 			return
 		}
 
-		line := scanner.Text()
-		l := lexer.New(line)
-		p := parser.New(l)
+		if strings.TrimSpace(input) == "" {
+			continue
+		}
 
-		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+		if isMetaCommand(input) {
+			s.runMetaCommand(input)
 			continue
 		}
 
-		evaluated := evaluator.Eval(program, env)
-		if evaluated != nil {
-			io.WriteString(out, "[Out]> ")
-			io.WriteString(out, evaluated.Inspect())
-			io.WriteString(out, "\n")
+		s.lastInput = input
+		s.eval(input)
+	}
+}
+
+func newSession(in io.Reader, out io.Writer, engine string) *session {
+	s := &session{out: out, engine: engine, host: object.NewHost(in, out)}
+	s.initState()
+	return s
+}
+
+func (s *session) initState() {
+	s.env = object.NewEnvironment()
+	s.env.SetHost(s.host)
+	s.macroEnv = object.NewEnvironment()
+
+	s.symbolTable = compiler.NewSymbolTable()
+	for i, v := range object.Builtins {
+		s.symbolTable.DefineBuiltin(i, v.Name)
+	}
+	s.constants = []object.Object{}
+	s.globals = make([]object.Object, vm.GlobalSize)
+}
+
+func (s *session) eval(input string) {
+	l := lexer.New(input)
+	p := parser.New(l)
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParserErrors(s.out, p.Errors())
+		return
+	}
+
+	evaluator.DefineMacros(program, s.macroEnv)
+	if err := evaluator.ExpandMacros(program, s.macroEnv); err != nil {
+		fmt.Fprintf(s.out, "macro expansion failed: %s\n", err)
+		return
+	}
+
+	var evaluated object.Object
+	if s.engine == ENGINE_VM {
+		evaluated = s.runVM(program)
+	} else {
+		evaluated = evaluator.Eval(program, s.env)
+	}
+
+	// only an expression has a result, any other statement leaves behind
+	// whatever the engine evaluated last
+	_, isError := evaluated.(*object.Error)
+	if evaluated != nil && (isError || program.HasResult()) {
+		io.WriteString(s.out, "[Out]> ")
+		io.WriteString(s.out, evaluated.Inspect())
+		io.WriteString(s.out, "\n")
+	}
+}
+
+func (s *session) runVM(program *ast.Program) object.Object {
+	// compile against a copy so a failed compilation leaves no half-defined globals behind
+	symbolTable := s.symbolTable.Copy()
+
+	comp := compiler.NewWithState(symbolTable, s.constants)
+	if err := comp.Compile(program); err != nil {
+		fmt.Fprintf(s.out, "compilation failed: %s\n", err)
+		return nil
+	}
+
+	bytecode := comp.ByteCode()
+	s.symbolTable = symbolTable
+	s.constants = bytecode.Constants
+
+	machine := vm.NewWithGlobalsStore(bytecode, s.globals)
+	machine.SetHost(s.host)
+	if err := machine.Run(); err != nil {
+		fmt.Fprintf(s.out, "ERROR: %s\n", err)
+		return nil
+	}
+
+	return machine.LastPoppedStackElem()
+}
+
+// readInput reads lines until every opened paren, brace and bracket is closed,
+// so functions and hashes can be spread over several lines.
+func readInput(host *object.Host) (string, bool) {
+	input, ok := host.ReadLine()
+	if !ok {
+		return "", false
+	}
+
+	for openDelimiters(input) > 0 {
+		io.WriteString(host.Stdout, PS2)
+		line, ok := host.ReadLine()
+		if !ok {
+			break
 		}
+		input += "\n" + line
+	}
+
+	return input, true
+}
 
+func openDelimiters(input string) int {
+	depth := 0
+
+	l := lexer.New(input)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET, token.TEMPLATE_START:
+			depth++
+		case token.RPAREN, token.RBRACE, token.RBRACKET, token.TEMPLATE_END:
+			depth--
+		case token.ILLEGAL:
+			// a block comment or a string that is not closed yet
+			if strings.HasPrefix(tok.Literal, "/*") || strings.HasPrefix(tok.Literal, `"`) {
+				depth++
+			}
+		}
 	}
+
+	return depth
 }
 
 func printParserErrors(out io.Writer, errors []string) {
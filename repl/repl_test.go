@@ -0,0 +1,143 @@
+package repl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestOpenDelimiters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		{"let x = 5;", 0},
+		{"let f = fn(a) {", 1},
+		{"let f = fn(a) { [1, (2", 3},
+		{"}", -1},
+		{`"({["`, 0},
+		{"// {", 0},
+		{"fn() { /* }", 2},
+		{`let s = "abc`, 1},
+		{`"a ${ fn() {`, 2},
+		{`"a ${ {"b": "}"}["b"] } c"`, 0},
+	}
+
+	for _, tt := range tests {
+		if got := openDelimiters(tt.input); got != tt.expected {
+			t.Errorf("openDelimiters(%q) wrong. want=%d, got=%d", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestStart(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			"let add = fn(a, b) {\n  a + b\n};\nadd(1,\n  2)\n",
+			[]string{"[Out]> 3\n"},
+		},
+		{
+			"let x = 1;\nlet y = 2;\n:env\n:reset\n:env\nx\n",
+			[]string{"x = 1\ny = 2\n", "environment cleared\n", "identifier not found: x"},
+		},
+		{
+			":tokens let x\n",
+			[]string{"1:1\tLET", "1:5\tIDENT"},
+		},
+		{
+			":tokens x // note\n",
+			[]string{"1:1\tIDENT", "1:3\tCOMMENT    \"// note\""},
+		},
+		{
+			"1 + /* two\nlines */ 2 // done\n",
+			[]string{"[Out]> 3\n"},
+		},
+		{
+			"1 + 2\n:ast\n",
+			[]string{"*ast.ExpressionStatement\t(1 + 2)\n"},
+		},
+		{
+			":bytecode 1 + 2\n",
+			[]string{"0000 OpConstant 0\n0003 OpConstant 1\n0006 OpAdd\n0007 OpPop\n", "0000 INTEGER 1\n0001 INTEGER 2\n"},
+		},
+		{
+			":nope\n",
+			[]string{"unknown command :nope"},
+		},
+		{
+			"let name = gets();\nada\nputs(name)\nfn() {\n",
+			[]string{"[In]> [In]> ada\n[Out]> null\n[In]>   ... "},
+		},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		Start(strings.NewReader(tt.input), &out)
+
+		for _, expected := range tt.expected {
+			if !strings.Contains(out.String(), expected) {
+				t.Errorf("output for %q does not contain %q. got=%q", tt.input, expected, out.String())
+			}
+		}
+	}
+}
+
+func TestStartVM(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			"let a = 5;\nlet f = fn(x) {\n  x + a\n};\nf(2)\n",
+			[]string{"[Out]> 7\n"},
+		},
+		{
+			"let a = 1;\nlet b = zz;\nlet c = 2;\n:env\n",
+			[]string{"unkown symbol zz", "a = 1\nc = 2\n"},
+		},
+		{
+			"let a = 1;\n:reset\na\n",
+			[]string{"environment cleared\n", "unkown symbol a"},
+		},
+		{
+			"let a = 1;\n:bytecode a\n",
+			[]string{"0000 OpGetGlobal 0\n"},
+		},
+		{
+			"let name = gets();\nada\nputs(name)\n",
+			[]string{"[In]> ada\n[Out]> null\n"},
+		},
+		{
+			"let double = macro(x) { quote(unquote(x) * 2) };\ndouble(1 + 2)\ndouble()\n",
+			[]string{"[Out]> 6\n", "macro expansion failed: 1:7: wrong number of arguments to macro: want=1, got=0"},
+		},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		StartVM(strings.NewReader(tt.input), &out)
+
+		for _, expected := range tt.expected {
+			if !strings.Contains(out.String(), expected) {
+				t.Errorf("output for %q does not contain %q. got=%q", tt.input, expected, out.String())
+			}
+		}
+	}
+}
+
+func TestStatementsWithoutResult(t *testing.T) {
+	input := "let f = fn(x) { x };\nlet s = gets();\nada\nwhile (false) { }\nlet x = 1; x\n"
+	expected := "[In]> [In]> [In]> [In]> [Out]> 1\n[In]> "
+
+	for _, engine := range []string{ENGINE_EVAL, ENGINE_VM} {
+		var out bytes.Buffer
+		start(newSession(strings.NewReader(input), &out, engine))
+
+		if out.String() != expected {
+			t.Errorf("[%s] wrong output. want=%q, got=%q", engine, expected, out.String())
+		}
+	}
+}
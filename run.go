@@ -0,0 +1,149 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Soj447/gonk/ast"
+	"github.com/Soj447/gonk/compiler"
+	"github.com/Soj447/gonk/evaluator"
+	"github.com/Soj447/gonk/lexer"
+	"github.com/Soj447/gonk/object"
+	"github.com/Soj447/gonk/parser"
+	"github.com/Soj447/gonk/repl"
+	"github.com/Soj447/gonk/token"
+	"github.com/Soj447/gonk/vm"
+)
+
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// runCommand implements `gonk run [-engine=eval|vm] [-O] file.gonk [args...]`.
+// The arguments following the file name are available to the script as the
+// `args` array of strings. -O optimizes the bytecode run by the vm engine.
+func runCommand(arguments []string, stderr io.Writer) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	engine := flags.String("engine", repl.ENGINE_EVAL, "execution backend: eval or vm")
+	optimize := flags.Bool("O", false, "optimize the bytecode of the vm engine")
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: gonk run [-engine=eval|vm] [-O] file.gonk [args...]\n")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(arguments); err != nil {
+		return exitUsage
+	}
+
+	if flags.NArg() < 1 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	if *engine != repl.ENGINE_EVAL && *engine != repl.ENGINE_VM {
+		fmt.Fprintf(stderr, "unknown engine %q\n", *engine)
+		return exitUsage
+	}
+
+	program, ok := loadProgram(flags.Arg(0), stderr)
+	if !ok {
+		return exitError
+	}
+
+	program.Statements = append([]ast.Statement{argsStatement(flags.Args()[1:])}, program.Statements...)
+
+	if *engine == repl.ENGINE_VM {
+		return runVM(program, *optimize, stderr)
+	}
+	return runEval(program, stderr)
+}
+
+// loadProgram parses the script at path and expands its macros, reporting
+// any error to stderr.
+func loadProgram(path string, stderr io.Writer) (*ast.Program, bool) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s\n", err)
+		return nil, false
+	}
+
+	l := lexer.NewFile(path, string(source))
+	p := parser.New(l)
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		for _, msg := range p.Errors() {
+			fmt.Fprintf(stderr, "%s\n", msg)
+		}
+		return nil, false
+	}
+
+	macroEnv := object.NewEnvironment()
+	evaluator.DefineMacros(program, macroEnv)
+	if err := evaluator.ExpandMacros(program, macroEnv); err != nil {
+		fmt.Fprintf(stderr, "macro expansion failed: %s\n", err)
+		return nil, false
+	}
+
+	return program, true
+}
+
+func runEval(program *ast.Program, stderr io.Writer) int {
+	env := object.NewEnvironment()
+
+	result := evaluator.Eval(program, env)
+	if errObj, ok := result.(*object.Error); ok {
+		fmt.Fprintf(stderr, "%s\n", errObj.Inspect())
+		return exitError
+	}
+
+	return exitOK
+}
+
+func runVM(program *ast.Program, optimize bool, stderr io.Writer) int {
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		fmt.Fprintf(stderr, "compilation failed: %s\n", err)
+		return exitError
+	}
+
+	bytecode := comp.ByteCode()
+	if optimize {
+		bytecode = compiler.Optimize(bytecode)
+	}
+
+	machine := vm.New(bytecode)
+	if err := machine.Run(); err != nil {
+		fmt.Fprintf(stderr, "ERROR: %s\n", err)
+		return exitError
+	}
+
+	return exitOK
+}
+
+// argsStatement builds `let args = [...]` so both backends see the script
+// arguments without any special support.
+func argsStatement(args []string) ast.Statement {
+	elements := []ast.Expression{}
+	for _, arg := range args {
+		tok := token.Token{Type: token.STRING, Literal: arg}
+		elements = append(elements, &ast.StringLiteral{Token: tok, Value: arg})
+	}
+
+	return &ast.LetStatement{
+		Token: token.Token{Type: token.LET, Literal: "let"},
+		Name: &ast.Identifier{
+			Token: token.Token{Type: token.IDENT, Literal: "args"},
+			Value: "args",
+		},
+		Value: &ast.ArrayLiteral{
+			Token:    token.Token{Type: token.LBRACKET, Literal: "["},
+			Elements: elements,
+		},
+	}
+}
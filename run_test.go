@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Soj447/gonk/repl"
+)
+
+func TestRunCommand(t *testing.T) {
+	tests := []struct {
+		source       string
+		args         []string
+		expectedCode int
+		expectedErr  string
+	}{
+		{`let x = 1 + 2; x`, nil, exitOK, ""},
+		{`if (len(args) != 2) { 1 + true }`, []string{"a", "b"}, exitOK, ""},
+		{"let x = 1;\nlet = 2;", nil, exitError, "script.gonk:2:5: expected next token to be IDENT"},
+		{"let f = fn() {\n  -true\n};\nf();", nil, exitError, "script.gonk:2:3: "},
+		{"len(1);\nputs(\"after\")", nil, exitError, "script.gonk:1:4: argument to `len` not supported"},
+	}
+
+	flagSets := [][]string{
+		{"-engine=" + repl.ENGINE_EVAL},
+		{"-engine=" + repl.ENGINE_VM},
+		{"-engine=" + repl.ENGINE_VM, "-O"},
+	}
+
+	for _, flags := range flagSets {
+		engine := strings.Join(flags, " ")
+		for _, tt := range tests {
+			path := filepath.Join(t.TempDir(), "script.gonk")
+			if err := os.WriteFile(path, []byte(tt.source), 0644); err != nil {
+				t.Fatalf("could not write script: %s", err)
+			}
+
+			var stderr bytes.Buffer
+			arguments := append(append([]string{}, flags...), path)
+			arguments = append(arguments, tt.args...)
+
+			code := runCommand(arguments, &stderr)
+			if code != tt.expectedCode {
+				t.Errorf("[%s] wrong exit code for %q. want=%d, got=%d (stderr=%q)",
+					engine, tt.source, tt.expectedCode, code, stderr.String())
+			}
+
+			if !strings.Contains(stderr.String(), tt.expectedErr) {
+				t.Errorf("[%s] stderr does not contain %q. got=%q",
+					engine, tt.expectedErr, stderr.String())
+			}
+		}
+	}
+}
+
+func TestRunCommandImports(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"lib.gonk":    "let x = 1 + 2;",
+		"script.gonk": `import "./lib.gonk" as lib; if (lib.x != 3) { 1 + true }`,
+	}
+	for name, source := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(source), 0644); err != nil {
+			t.Fatalf("could not write script: %s", err)
+		}
+	}
+
+	for _, engine := range []string{repl.ENGINE_EVAL, repl.ENGINE_VM} {
+		var stderr bytes.Buffer
+
+		code := runCommand([]string{"-engine=" + engine, filepath.Join(dir, "script.gonk")}, &stderr)
+		if code != exitOK {
+			t.Errorf("[%s] wrong exit code. want=%d, got=%d (stderr=%q)", engine, exitOK, code, stderr.String())
+		}
+	}
+}
+
+func TestRunCommandUsage(t *testing.T) {
+	var stderr bytes.Buffer
+
+	if code := runCommand([]string{}, &stderr); code != exitUsage {
+		t.Errorf("wrong exit code without file. want=%d, got=%d", exitUsage, code)
+	}
+
+	if code := runCommand([]string{"-engine=jit", "x.gonk"}, &stderr); code != exitUsage {
+		t.Errorf("wrong exit code for unknown engine. want=%d, got=%d", exitUsage, code)
+	}
+}
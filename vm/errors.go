@@ -0,0 +1,27 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/Soj447/gonk/token"
+)
+
+// RuntimeError wraps an error raised while executing an instruction together
+// with the source position the instruction was compiled from.
+type RuntimeError struct {
+	Pos token.Position
+	Err error
+}
+
+func (e *RuntimeError) Error() string {
+	if e.Pos.IsValid() {
+		return fmt.Sprintf("%s: %s", e.Pos, e.Err)
+	}
+	return e.Err.Error()
+}
+
+func (e *RuntimeError) Unwrap() error { return e.Err }
+
+func (vm *VM) runtimeError(err error, frame *Frame, ip int) error {
+	return &RuntimeError{Pos: frame.cl.Fn.SourceMap[ip], Err: err}
+}
@@ -0,0 +1,20 @@
+package vm
+
+import (
+	"github.com/Soj447/gonk/code"
+	"github.com/Soj447/gonk/object"
+)
+
+type Frame struct {
+	cl          *object.Closure
+	ip          int
+	basePointer int // stack pointer value before the call; arguments start here
+}
+
+func NewFrame(cl *object.Closure, basePointer int) *Frame {
+	return &Frame{cl: cl, ip: -1, basePointer: basePointer}
+}
+
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}
@@ -1,7 +1,11 @@
 package vm
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math"
+	"strings"
 
 	"github.com/Soj447/gonk/code"
 	"github.com/Soj447/gonk/compiler"
@@ -10,30 +14,80 @@ import (
 
 const StackSize = 2048
 const GlobalSize = 65536
+const MaxFrames = 1024
 
 type VM struct {
-	constants    []object.Object
-	instructions code.Instructions
-	globals      []object.Object
+	constants []object.Object
+	globals   []object.Object
 
 	stack []object.Object
 	sp    int
+
+	frames      []*Frame
+	framesIndex int
+
+	monitor *object.Monitor // limits of the current run, nil when there are none
+	host    *object.Host
 }
 
-var True = &object.Boolean{Value: true}
-var False = &object.Boolean{Value: false}
-var Null = &object.Null{}
+var True = object.TRUE
+var False = object.FALSE
+var Null = object.NULL
 
 func New(bytecode *compiler.ByteCode) *VM {
+	mainFn := &object.CompiledFunction{
+		Instructions: bytecode.Instructions,
+		SourceMap:    bytecode.SourceMap,
+	}
+	mainClosure := &object.Closure{Fn: mainFn}
+	mainFrame := NewFrame(mainClosure, 0)
+
+	frames := make([]*Frame, MaxFrames)
+	frames[0] = mainFrame
+
 	return &VM{
-		constants:    bytecode.Constants,
-		instructions: bytecode.Instructions,
-		globals:      make([]object.Object, GlobalSize),
-		stack:        make([]object.Object, StackSize),
-		sp:           0,
+		constants:   bytecode.Constants,
+		globals:     make([]object.Object, GlobalSize),
+		stack:       make([]object.Object, StackSize),
+		sp:          0,
+		frames:      frames,
+		framesIndex: 1,
+		host:        object.StdHost,
 	}
 }
 
+// NewWithGlobalsStore creates a VM that reads and writes the given globals, so
+// state survives between runs of separately compiled bytecode.
+func NewWithGlobalsStore(bytecode *compiler.ByteCode, s []object.Object) *VM {
+	vm := New(bytecode)
+	vm.globals = s
+	return vm
+}
+
+// SetHost sets the host builtins use, instead of object.StdHost.
+func (vm *VM) SetHost(h *object.Host) {
+	vm.host = h
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) error {
+	if vm.framesIndex >= MaxFrames {
+		return fmt.Errorf("frame overflow")
+	}
+
+	vm.frames[vm.framesIndex] = f
+	vm.framesIndex++
+	return nil
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
 func (vm *VM) StackTop() object.Object {
 	if vm.sp == 0 {
 		return nil
@@ -46,110 +100,436 @@ func (vm *VM) LastPoppedStackElem() object.Object {
 }
 
 func (vm *VM) Run() error {
-	for ip := 0; ip < len(vm.instructions); ip++ {
-		op := code.Opcode(vm.instructions[ip])
+	return vm.run()
+}
+
+// RunContext runs like Run, but stops with an error once ctx is done or the
+// program exceeds limits.
+func (vm *VM) RunContext(ctx context.Context, limits object.Limits) error {
+	vm.monitor = object.NewMonitor(ctx, limits)
+	defer func() { vm.monitor = nil }()
+
+	return vm.run()
+}
+
+func (vm *VM) run() error {
+	var ip int
+	var ins code.Instructions
+	var op code.Opcode
+	var frame *Frame
+
+	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		vm.currentFrame().ip++
+
+		frame = vm.currentFrame()
+		ip = frame.ip
+		ins = frame.Instructions()
+		op = code.Opcode(ins[ip])
+
+		if err := vm.monitor.Step(); err != nil {
+			return vm.runtimeError(err, frame, ip)
+		}
 
 		switch op {
 		case code.OpConstant:
-			constIndex := code.ReadUint16(vm.instructions[ip+1:])
-			ip += 2
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			err := vm.push(vm.constants[constIndex])
+			if err != nil {
+				return vm.runtimeError(err, frame, ip)
+			}
+		case code.OpConstantWide:
+			constIndex := code.ReadUint32(ins[ip+1:])
+			vm.currentFrame().ip += 4
 			err := vm.push(vm.constants[constIndex])
 			if err != nil {
-				return err
+				return vm.runtimeError(err, frame, ip)
 			}
 		case code.OpTrue:
 			err := vm.push(True)
 			if err != nil {
-				return err
+				return vm.runtimeError(err, frame, ip)
 			}
 		case code.OpFalse:
 			err := vm.push(False)
 			if err != nil {
-				return err
+				return vm.runtimeError(err, frame, ip)
 			}
 		case code.OpNull:
 			err := vm.push(Null)
 			if err != nil {
-				return err
+				return vm.runtimeError(err, frame, ip)
 			}
-		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv:
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod:
 			err := vm.executeBinaryOperation(op)
 			if err != nil {
-				return err
+				return vm.runtimeError(err, frame, ip)
 			}
-		case code.OpEqual, code.OpNotEqual, code.OpGreaterThan:
+		case code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpGreaterThanOrEqual,
+			code.OpLessThan, code.OpLessThanOrEqual:
 			err := vm.executeComparisonOperation(op)
 			if err != nil {
-				return err
+				return vm.runtimeError(err, frame, ip)
 			}
 		case code.OpBang:
 			err := vm.executeBangOperator()
 			if err != nil {
-				return err
+				return vm.runtimeError(err, frame, ip)
 			}
 		case code.OpMinus:
 			err := vm.executeMinusOperator()
 			if err != nil {
-				return err
+				return vm.runtimeError(err, frame, ip)
 			}
 		case code.OpPop:
 			vm.pop()
-		case code.OpJump:
-			jumpIndex := int(code.ReadUint16(vm.instructions[ip+1:]))
-			ip = jumpIndex - 1
-		case code.OpJumpNotTruthy:
-			jumpIndex := int(code.ReadUint16(vm.instructions[ip+1:]))
-			ip += 2
+		case code.OpJump, code.OpJumpWide:
+			jumpIndex, _ := readJumpTarget(op, ins[ip+1:])
+			vm.currentFrame().ip = jumpIndex - 1
+		case code.OpJumpNotTruthy, code.OpJumpNotTruthyWide:
+			jumpIndex, width := readJumpTarget(op, ins[ip+1:])
+			vm.currentFrame().ip += width
 
 			condition := vm.pop()
 			if !isTruthy(condition) {
-				ip = jumpIndex - 1
+				vm.currentFrame().ip = jumpIndex - 1
+			}
+		case code.OpJumpTruthy, code.OpJumpTruthyWide:
+			jumpIndex, width := readJumpTarget(op, ins[ip+1:])
+			vm.currentFrame().ip += width
+
+			condition := vm.pop()
+			if isTruthy(condition) {
+				vm.currentFrame().ip = jumpIndex - 1
 			}
 		case code.OpGetGlobal:
-			globalIndex := int(code.ReadUint16(vm.instructions[ip+1:]))
-			ip += 2
+			globalIndex := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
 
 			err := vm.push(vm.globals[globalIndex])
 			if err != nil {
-				return err
+				return vm.runtimeError(err, frame, ip)
 			}
 
 		case code.OpSetGlobal:
-			globalIndex := int(code.ReadUint16(vm.instructions[ip+1:]))
-			ip += 2
+			globalIndex := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
 
 			vm.globals[globalIndex] = vm.pop()
 		case code.OpArray:
-			arrayLength := int(code.ReadUint16(vm.instructions[ip+1:]))
-			ip += 2
+			arrayLength := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
 
 			array := vm.buildArray(vm.sp-arrayLength, vm.sp)
 			vm.sp = vm.sp - arrayLength
-			vm.push(array)
+
+			err := vm.monitor.Alloc(array)
+			if err == nil {
+				err = vm.push(array)
+			}
+			if err != nil {
+				return vm.runtimeError(err, frame, ip)
+			}
 		case code.OpHash:
-			hashLength := int(code.ReadUint16(vm.instructions[ip+1:]))
-			ip += 2
+			hashLength := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
 
 			hashMap, err := vm.buildHash(vm.sp-hashLength, vm.sp)
 			if err != nil {
-				return err
+				return vm.runtimeError(err, frame, ip)
 			}
 
 			vm.sp = vm.sp - hashLength
 			err = vm.push(hashMap)
 			if err != nil {
-				return err
+				return vm.runtimeError(err, frame, ip)
 			}
 		case code.OpIndex:
 			err := vm.executeIndexExpression()
 			if err != nil {
-				return err
+				return vm.runtimeError(err, frame, ip)
+			}
+		case code.OpGetLocal:
+			localIndex := int(code.ReadUint8(ins[ip+1:]))
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			local := vm.stack[frame.basePointer+localIndex]
+			if cell, ok := local.(*object.Cell); ok {
+				local = cell.Value
+			}
+
+			err := vm.push(local)
+			if err != nil {
+				return vm.runtimeError(err, frame, ip)
+			}
+		case code.OpSetLocal:
+			localIndex := int(code.ReadUint8(ins[ip+1:]))
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			slot := frame.basePointer + localIndex
+			if cell, ok := vm.stack[slot].(*object.Cell); ok {
+				cell.Value = vm.pop()
+			} else {
+				vm.stack[slot] = vm.pop()
+			}
+		case code.OpCaptureLocal:
+			localIndex := int(code.ReadUint8(ins[ip+1:]))
+			vm.currentFrame().ip += 1
+
+			slot := vm.currentFrame().basePointer + localIndex
+			cell, ok := vm.stack[slot].(*object.Cell)
+			if !ok {
+				cell = &object.Cell{Value: vm.stack[slot]}
+				vm.stack[slot] = cell
+			}
+
+			err := vm.push(cell)
+			if err != nil {
+				return vm.runtimeError(err, frame, ip)
+			}
+		case code.OpIter:
+			iterator, err := object.NewIterator(vm.pop())
+			if err != nil {
+				return vm.runtimeError(err, frame, ip)
+			}
+
+			err = vm.push(iterator)
+			if err != nil {
+				return vm.runtimeError(err, frame, ip)
+			}
+		case code.OpIterNext, code.OpIterNextWide:
+			exitIndex, width := readJumpTarget(op, ins[ip+1:])
+			vm.currentFrame().ip += width
+
+			iterator := vm.pop().(*object.Iterator)
+			element, ok := iterator.Next()
+			if !ok {
+				vm.currentFrame().ip = exitIndex - 1
+				continue
+			}
+
+			err := vm.push(element)
+			if err != nil {
+				return vm.runtimeError(err, frame, ip)
+			}
+		case code.OpGetBuiltin:
+			builtinIndex := int(code.ReadUint8(ins[ip+1:]))
+			vm.currentFrame().ip += 1
+
+			definition := object.Builtins[builtinIndex]
+			err := vm.push(definition.Builtin)
+			if err != nil {
+				return vm.runtimeError(err, frame, ip)
+			}
+		case code.OpCall:
+			numArgs := int(code.ReadUint8(ins[ip+1:]))
+			vm.currentFrame().ip += 1
+
+			err := vm.executeCall(numArgs)
+			if err != nil {
+				return vm.runtimeError(err, frame, ip)
+			}
+		case code.OpClosure:
+			constIndex := int(code.ReadUint16(ins[ip+1:]))
+			numFree := int(code.ReadUint8(ins[ip+3:]))
+			vm.currentFrame().ip += 3
+
+			err := vm.pushClosure(constIndex, numFree)
+			if err != nil {
+				return vm.runtimeError(err, frame, ip)
+			}
+		case code.OpClosureWide:
+			constIndex := int(code.ReadUint32(ins[ip+1:]))
+			numFree := int(code.ReadUint8(ins[ip+5:]))
+			vm.currentFrame().ip += 5
+
+			err := vm.pushClosure(constIndex, numFree)
+			if err != nil {
+				return vm.runtimeError(err, frame, ip)
+			}
+		case code.OpGetFree:
+			freeIndex := int(code.ReadUint8(ins[ip+1:]))
+			vm.currentFrame().ip += 1
+
+			currentClosure := vm.currentFrame().cl
+			err := vm.push(currentClosure.Free[freeIndex].(*object.Cell).Value)
+			if err != nil {
+				return vm.runtimeError(err, frame, ip)
+			}
+		case code.OpSetFree:
+			freeIndex := int(code.ReadUint8(ins[ip+1:]))
+			vm.currentFrame().ip += 1
+
+			currentClosure := vm.currentFrame().cl
+			currentClosure.Free[freeIndex].(*object.Cell).Value = vm.pop()
+		case code.OpCaptureFree:
+			freeIndex := int(code.ReadUint8(ins[ip+1:]))
+			vm.currentFrame().ip += 1
+
+			err := vm.push(vm.currentFrame().cl.Free[freeIndex])
+			if err != nil {
+				return vm.runtimeError(err, frame, ip)
+			}
+		case code.OpConcat:
+			numParts := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			str := vm.buildString(vm.sp-numParts, vm.sp)
+			vm.sp = vm.sp - numParts
+
+			err := vm.monitor.Alloc(str)
+			if err == nil {
+				err = vm.push(str)
+			}
+			if err != nil {
+				return vm.runtimeError(err, frame, ip)
+			}
+		case code.OpModule:
+			nameIndex := code.ReadUint16(ins[ip+1:])
+			numMembers := int(code.ReadUint16(ins[ip+3:]))
+			vm.currentFrame().ip += 4
+
+			module := vm.buildModule(vm.constants[nameIndex], vm.sp-numMembers, vm.sp)
+			vm.sp = vm.sp - numMembers
+
+			err := vm.push(module)
+			if err != nil {
+				return vm.runtimeError(err, frame, ip)
+			}
+		case code.OpSetIndex:
+			err := vm.executeSetIndex()
+			if err != nil {
+				return vm.runtimeError(err, frame, ip)
+			}
+		case code.OpCurrentClosure:
+			err := vm.push(vm.currentFrame().cl)
+			if err != nil {
+				return vm.runtimeError(err, frame, ip)
+			}
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+
+			// a return at the top level ends the program with the value as the last popped one
+			if vm.framesIndex == 1 {
+				return nil
+			}
+
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1 // also drop the called function itself
+			vm.monitor.Leave()
+
+			err := vm.push(returnValue)
+			if err != nil {
+				return vm.runtimeError(err, frame, ip)
+			}
+		case code.OpReturn:
+			if vm.framesIndex == 1 {
+				return nil
+			}
+
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+			vm.monitor.Leave()
+
+			err := vm.push(Null)
+			if err != nil {
+				return vm.runtimeError(err, frame, ip)
 			}
 		}
 	}
 	return nil
 }
 
+func (vm *VM) executeCall(numArgs int) error {
+	callee := vm.stack[vm.sp-1-numArgs]
+
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs)
+	case *object.Builtin:
+		return vm.callBuiltin(callee, numArgs)
+	default:
+		return fmt.Errorf("calling non-function")
+	}
+}
+
+func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
+	args := vm.stack[vm.sp-numArgs : vm.sp]
+
+	result := builtin.Fn(vm.host, args...)
+	vm.sp = vm.sp - numArgs - 1
+
+	// a failed builtin stops the program like any other runtime error
+	if errObj, ok := result.(*object.Error); ok {
+		if errObj.Err != nil {
+			return errObj.Err
+		}
+		return errors.New(errObj.Message)
+	}
+
+	if err := vm.monitor.Alloc(result); err != nil {
+		return err
+	}
+
+	if result != nil {
+		return vm.push(result)
+	}
+	return vm.push(Null)
+}
+
+func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
+	fn := cl.Fn
+	if numArgs != fn.NumParameters {
+		return fmt.Errorf("wrong number of arguments: want=%d, got=%d",
+			fn.NumParameters, numArgs)
+	}
+
+	if err := vm.monitor.Enter(); err != nil {
+		return err
+	}
+
+	frame := NewFrame(cl, vm.sp-numArgs)
+	err := vm.pushFrame(frame)
+	if err != nil {
+		return err
+	}
+
+	// reserve the slots for the locals of the function (arguments already occupy the first ones)
+	vm.sp = frame.basePointer + fn.NumLocals
+	if vm.sp >= StackSize {
+		return fmt.Errorf("stack overflow")
+	}
+
+	// clear the slots, a cell left by an earlier call must not be mistaken for a captured local
+	for i := frame.basePointer + numArgs; i < vm.sp; i++ {
+		vm.stack[i] = nil
+	}
+
+	return nil
+}
+
+func (vm *VM) pushClosure(constIndex, numFree int) error {
+	constant := vm.constants[constIndex]
+	fn, ok := constant.(*object.CompiledFunction)
+	if !ok {
+		return fmt.Errorf("not a function: %+v", constant)
+	}
+
+	free := make([]object.Object, numFree)
+	for i := 0; i < numFree; i++ {
+		free[i] = vm.stack[vm.sp-numFree+i]
+		// the current closure is captured by value, it never changes
+		if _, ok := free[i].(*object.Cell); !ok {
+			free[i] = &object.Cell{Value: free[i]}
+		}
+	}
+	vm.sp = vm.sp - numFree
+
+	return vm.push(&object.Closure{Fn: fn, Free: free})
+}
+
 func (vm *VM) push(obj object.Object) error {
 	if vm.sp >= StackSize {
 		return fmt.Errorf("stack overflow")
@@ -176,6 +556,26 @@ func (vm *VM) buildArray(start, end int) *object.Array {
 	return &object.Array{Elements: elements}
 }
 
+func (vm *VM) buildString(start, end int) *object.String {
+	var out strings.Builder
+
+	for i := start; i < end; i++ {
+		out.WriteString(vm.stack[i].Inspect())
+	}
+
+	return &object.String{Value: out.String()}
+}
+
+func (vm *VM) buildModule(name object.Object, start, end int) *object.Module {
+	members := make(map[string]object.Object)
+
+	for i := start; i < end; i += 2 {
+		members[vm.stack[i].(*object.String).Value] = vm.stack[i+1]
+	}
+
+	return &object.Module{Name: name.(*object.String).Value, Members: members}
+}
+
 func (vm *VM) buildHash(start, end int) (*object.Hash, error) {
 	hashPairs := make(map[object.HashKey]object.HashPair)
 
@@ -203,6 +603,9 @@ func (vm *VM) executeBinaryOperation(op code.Opcode) error {
 	if leftObj.Type() == object.INTEGER_OBJ && rightObj.Type() == object.INTEGER_OBJ {
 		return vm.executeIntegerBinaryOperation(op, leftObj, rightObj)
 	}
+	if isNumber(leftObj) && isNumber(rightObj) {
+		return vm.executeFloatBinaryOperation(op, leftObj, rightObj)
+	}
 	if leftObj.Type() == object.STRING_OBJ && rightObj.Type() == object.STRING_OBJ {
 		return vm.executeStringBinaryOperation(op, leftObj, rightObj)
 	}
@@ -224,7 +627,15 @@ func (vm *VM) executeIntegerBinaryOperation(op code.Opcode, leftObj, rightObj ob
 	case code.OpMul:
 		result = leftVal * rightVal
 	case code.OpDiv:
+		if rightVal == 0 {
+			return fmt.Errorf("division by zero")
+		}
 		result = leftVal / rightVal
+	case code.OpMod:
+		if rightVal == 0 {
+			return fmt.Errorf("division by zero")
+		}
+		result = leftVal % rightVal
 	default:
 		return fmt.Errorf("unsupported integer operation: %d", op)
 	}
@@ -232,6 +643,30 @@ func (vm *VM) executeIntegerBinaryOperation(op code.Opcode, leftObj, rightObj ob
 	return vm.push(&object.Integer{Value: result})
 }
 
+func (vm *VM) executeFloatBinaryOperation(op code.Opcode, leftObj, rightObj object.Object) error {
+	leftVal := toFloat(leftObj)
+	rightVal := toFloat(rightObj)
+
+	var result float64
+
+	switch op {
+	case code.OpAdd:
+		result = leftVal + rightVal
+	case code.OpSub:
+		result = leftVal - rightVal
+	case code.OpMul:
+		result = leftVal * rightVal
+	case code.OpDiv:
+		result = leftVal / rightVal
+	case code.OpMod:
+		result = math.Mod(leftVal, rightVal)
+	default:
+		return fmt.Errorf("unsupported float operation: %d", op)
+	}
+
+	return vm.push(&object.Float{Value: result})
+}
+
 func (vm *VM) executeStringBinaryOperation(op code.Opcode, leftObj, rightObj object.Object) error {
 	leftVal := leftObj.(*object.String).Value
 	rightVal := rightObj.(*object.String).Value
@@ -240,7 +675,12 @@ func (vm *VM) executeStringBinaryOperation(op code.Opcode, leftObj, rightObj obj
 		return fmt.Errorf("unkown string operation: %d", op)
 	}
 
-	return vm.push(&object.String{Value: leftVal + rightVal})
+	result := &object.String{Value: leftVal + rightVal}
+	if err := vm.monitor.Alloc(result); err != nil {
+		return err
+	}
+
+	return vm.push(result)
 }
 
 func (vm *VM) executeComparisonOperation(op code.Opcode) error {
@@ -250,6 +690,12 @@ func (vm *VM) executeComparisonOperation(op code.Opcode) error {
 	if right.Type() == object.INTEGER_OBJ && left.Type() == object.INTEGER_OBJ {
 		return vm.executeIntegerComparisonOperation(op, left, right)
 	}
+	if isNumber(left) && isNumber(right) {
+		return vm.executeFloatComparisonOperation(op, left, right)
+	}
+	if right.Type() == object.STRING_OBJ && left.Type() == object.STRING_OBJ {
+		return vm.executeStringComparisonOperation(op, left, right)
+	}
 
 	switch op {
 	case code.OpEqual:
@@ -262,6 +708,23 @@ func (vm *VM) executeComparisonOperation(op code.Opcode) error {
 	}
 }
 
+// executeStringComparisonOperation compares strings by value, the same string
+// may be a constant or built at runtime.
+func (vm *VM) executeStringComparisonOperation(op code.Opcode, left, right object.Object) error {
+	leftVal := left.(*object.String).Value
+	rightVal := right.(*object.String).Value
+
+	switch op {
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(leftVal == rightVal))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(leftVal != rightVal))
+	default:
+		return fmt.Errorf("unknown operator: %d (%s %s)",
+			op, left.Type(), right.Type())
+	}
+}
+
 func (vm *VM) executeIntegerComparisonOperation(op code.Opcode, left, right object.Object) error {
 	leftVal := left.(*object.Integer).Value
 	rightVal := right.(*object.Integer).Value
@@ -273,6 +736,34 @@ func (vm *VM) executeIntegerComparisonOperation(op code.Opcode, left, right obje
 		return vm.push(nativeBoolToBooleanObject(leftVal != rightVal))
 	case code.OpGreaterThan:
 		return vm.push(nativeBoolToBooleanObject(leftVal > rightVal))
+	case code.OpGreaterThanOrEqual:
+		return vm.push(nativeBoolToBooleanObject(leftVal >= rightVal))
+	case code.OpLessThan:
+		return vm.push(nativeBoolToBooleanObject(leftVal < rightVal))
+	case code.OpLessThanOrEqual:
+		return vm.push(nativeBoolToBooleanObject(leftVal <= rightVal))
+	default:
+		return fmt.Errorf("unkown operator: %d", op)
+	}
+}
+
+func (vm *VM) executeFloatComparisonOperation(op code.Opcode, left, right object.Object) error {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
+
+	switch op {
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(leftVal == rightVal))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(leftVal != rightVal))
+	case code.OpGreaterThan:
+		return vm.push(nativeBoolToBooleanObject(leftVal > rightVal))
+	case code.OpGreaterThanOrEqual:
+		return vm.push(nativeBoolToBooleanObject(leftVal >= rightVal))
+	case code.OpLessThan:
+		return vm.push(nativeBoolToBooleanObject(leftVal < rightVal))
+	case code.OpLessThanOrEqual:
+		return vm.push(nativeBoolToBooleanObject(leftVal <= rightVal))
 	default:
 		return fmt.Errorf("unkown operator: %d", op)
 	}
@@ -296,12 +787,14 @@ func (vm *VM) executeBangOperator() error {
 func (vm *VM) executeMinusOperator() error {
 	operand := vm.pop()
 
-	if operand.Type() != object.INTEGER_OBJ {
+	switch operand := operand.(type) {
+	case *object.Integer:
+		return vm.push(&object.Integer{Value: -operand.Value})
+	case *object.Float:
+		return vm.push(&object.Float{Value: -operand.Value})
+	default:
 		return fmt.Errorf("unsupported type for minus operation: %s", operand.Type())
 	}
-
-	val := operand.(*object.Integer).Value
-	return vm.push(&object.Integer{Value: -val})
 }
 
 func (vm *VM) executeIndexExpression() error {
@@ -313,10 +806,41 @@ func (vm *VM) executeIndexExpression() error {
 		return vm.executeArrayIndex(left, index)
 	} else if left.Type() == object.HASH_OBJ {
 		return vm.executeHashIndex(left, index)
+	} else if left.Type() == object.MODULE_OBJ {
+		return vm.executeModuleIndex(left, index)
 	}
 	return fmt.Errorf("index is not supported for object with type=%s", left.Type())
 }
 
+func (vm *VM) executeSetIndex() error {
+	value := vm.pop()
+	index := vm.pop()
+	left := vm.pop()
+
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		arrayObject := left.(*object.Array)
+		idx := index.(*object.Integer).Value
+
+		if idx < 0 || idx >= int64(len(arrayObject.Elements)) {
+			return fmt.Errorf("index out of range: %d", idx)
+		}
+		arrayObject.Elements[idx] = value
+	case left.Type() == object.HASH_OBJ:
+		hashObject := left.(*object.Hash)
+
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return fmt.Errorf("unusable as hash key: %s", index.Type())
+		}
+		hashObject.Pairs[key.HashKey()] = object.HashPair{Key: index, Value: value}
+	default:
+		return fmt.Errorf("index assignment is not supported for object with type=%s", left.Type())
+	}
+
+	return vm.push(value)
+}
+
 func (vm *VM) executeArrayIndex(array, index object.Object) error {
 	arrayObject := array.(*object.Array)
 	indexObject := index.(*object.Integer)
@@ -344,6 +868,43 @@ func (vm *VM) executeHashIndex(hash, index object.Object) error {
 	return vm.push(pair.Value)
 }
 
+func (vm *VM) executeModuleIndex(module, index object.Object) error {
+	moduleObject := module.(*object.Module)
+
+	name, ok := index.(*object.String)
+	if !ok {
+		return fmt.Errorf("module members are indexed by name, got %s", index.Type())
+	}
+
+	member, ok := moduleObject.Members[name.Value]
+	if !ok {
+		return fmt.Errorf("module %q has no member %s", moduleObject.Name, name.Value)
+	}
+
+	return vm.push(member)
+}
+
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+func toFloat(obj object.Object) float64 {
+	if integer, ok := obj.(*object.Integer); ok {
+		return float64(integer.Value)
+	}
+	return obj.(*object.Float).Value
+}
+
+// readJumpTarget reads the target of a jump and the width of its operand.
+func readJumpTarget(op code.Opcode, operand code.Instructions) (int, int) {
+	switch op {
+	case code.OpJumpWide, code.OpJumpNotTruthyWide, code.OpJumpTruthyWide, code.OpIterNextWide:
+		return int(code.ReadUint32(operand)), 4
+	default:
+		return int(code.ReadUint16(operand)), 2
+	}
+}
+
 func nativeBoolToBooleanObject(native bool) *object.Boolean {
 	if native {
 		return True
@@ -1,8 +1,14 @@
 package vm
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/Soj447/gonk/ast"
 	"github.com/Soj447/gonk/compiler"
@@ -31,6 +37,28 @@ func TestIntegerArithmetic(t *testing.T) {
 		{"5 * 2 + 10", 20},
 		{"5 + 2 * 10", 25},
 		{"5 * (2 + 10)", 60},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestFloatArithmetic(t *testing.T) {
+	tests := []vmTestCase{
+		{"2.5", 2.5},
+		{"-2.5", -2.5},
+		{"1.5 + 1.25", 2.75},
+		{"1 + 0.5", 1.5},
+		{"0.5 * 4", 2.0},
+		{"7 / 2.0", 3.5},
+		{"10 - 2.5 * 2", 5.0},
+		{"1.5 < 2", true},
+		{"2 > 1.5", true},
+		{"1.0 == 1", true},
+		{"1.5 != 1.5", false},
+		{"7.5 % 2", 1.5},
+		{"1.5 >= 1", true},
 	}
 
 	runVmTests(t, tests)
@@ -52,6 +80,21 @@ func TestBooleanExpression(t *testing.T) {
 		{"false == false", true},
 		{"true == false", false},
 		{"true != false", true},
+		{"1 <= 1", true},
+		{"2 <= 1", false},
+		{"1 >= 2", false},
+		{"2 >= 2", true},
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 && 2", true},
+		{"if (false) { 1 } || 0", true},
+		{"false && (1 + true)", false},
+		{"true || (1 + true)", true},
+		{"let x = 0; let f = fn() { x = x + 1; true }; false && f(); true || f(); x", 0},
+		{`let s = ""; let a = fn() { s = s + "a"; 1 }; let b = fn() { s = s + "b"; 2 }; a() < b(); a() <= b(); s`, "abab"},
+		{"1.5 < 2; 2 <= 1.5", false},
 		{"false != true", true},
 		{"(1 < 2) == true", true},
 		{"(1 < 2) == false", false},
@@ -100,6 +143,21 @@ func TestStringExpressions(t *testing.T) {
 	tests := []vmTestCase{
 		{`"ebaj" + "PIS"`, "ebajPIS"},
 		{`"Ocaml" + "One" + "Love"`, "OcamlOneLove"},
+		{`"a" + "b" == "ab"`, true},
+		{`let s = "a"; s + "b" != "ab"`, false},
+		{`"a" == "b"`, false},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestTemplateLiterals(t *testing.T) {
+	tests := []vmTestCase{
+		{`let name = "Ann"; "hello ${name}!"`, "hello Ann!"},
+		{`let items = [1, 2]; "you have ${len(items)} items"`, "you have 2 items"},
+		{`"${1 + 1}${"b"}${true} ${[1, "x"]} ${1.5}"`, "2btrue [1, x] 1.5"},
+		{`"outer ${"inner ${1 * 3}"}"`, "outer inner 3"},
+		{`let f = fn(n) { "n=${n}" }; f(4)`, "n=4"},
 	}
 
 	runVmTests(t, tests)
@@ -157,6 +215,604 @@ func TestIndexExpressions(t *testing.T) {
 	runVmTests(t, tests)
 }
 
+func TestCallingFunctionsWithoutArguments(t *testing.T) {
+	tests := []vmTestCase{
+		{"let fivePlusTen = fn() { 5 + 10; }; fivePlusTen();", 15},
+		{"let one = fn() { 1; }; let two = fn() { 2; }; one() + two()", 3},
+		{"let a = fn() { 1 }; let b = fn() { a() + 1 }; let c = fn() { b() + 1 }; c();", 3},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestFunctionsWithReturnStatement(t *testing.T) {
+	tests := []vmTestCase{
+		{"let earlyExit = fn() { return 99; 100; }; earlyExit();", 99},
+		{"let earlyExit = fn() { return 99; return 100; }; earlyExit();", 99},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestTopLevelReturn(t *testing.T) {
+	tests := []vmTestCase{
+		{"1; return 5; 6;", 5},
+		{"let f = fn() { 1 }; if (f() > 0) { return f() + 9; } 20;", 10},
+		{"let i = 0; while (true) { let i = i + 1; if (i == 3) { return i; } }", 3},
+	}
+
+	runVmTests(t, tests)
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		result, err := runBytecode(compiler.Optimize(comp.ByteCode()))
+		if err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+		if result != fmt.Sprint(tt.expected) {
+			t.Errorf("wrong optimized result for %q. want=%v, got=%s", tt.input, tt.expected, result)
+		}
+	}
+}
+
+func TestFunctionsWithoutReturnValue(t *testing.T) {
+	tests := []vmTestCase{
+		{"let noReturn = fn() { }; noReturn();", Null},
+		{"let noReturn = fn() { }; let noReturnTwo = fn() { noReturn(); }; noReturn(); noReturnTwo();", Null},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestFirstClassFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{"let returnsOne = fn() { 1; }; let returnsOneReturner = fn() { returnsOne; }; returnsOneReturner()();", 1},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestCallingFunctionsWithBindings(t *testing.T) {
+	tests := []vmTestCase{
+		{"let one = fn() { let one = 1; one }; one();", 1},
+		{"let oneAndTwo = fn() { let one = 1; let two = 2; one + two; }; oneAndTwo();", 3},
+		{`
+		let oneAndTwo = fn() { let one = 1; let two = 2; one + two; };
+		let threeAndFour = fn() { let three = 3; let four = 4; three + four; };
+		oneAndTwo() + threeAndFour();`, 10},
+		{`
+		let globalSeed = 50;
+		let minusOne = fn() { let num = 1; globalSeed - num; };
+		let minusTwo = fn() { let num = 2; globalSeed - num; };
+		minusOne() + minusTwo();`, 97},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestCallingFunctionsWithArgumentsAndBindings(t *testing.T) {
+	tests := []vmTestCase{
+		{"let identity = fn(a) { a; }; identity(4);", 4},
+		{"let sum = fn(a, b) { a + b; }; sum(1, 2);", 3},
+		{"let sum = fn(a, b) { let c = a + b; c; }; sum(1, 2) + sum(3, 4);", 10},
+		{`
+		let sum = fn(a, b) { let c = a + b; c; };
+		let outer = fn() { sum(1, 2) + sum(3, 4); };
+		outer();`, 10},
+		{`
+		let globalNum = 10;
+		let sum = fn(a, b) { let c = a + b; c + globalNum; };
+		let outer = fn() { sum(1, 2) + sum(3, 4) + globalNum; };
+		outer() + globalNum;`, 50},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestClosures(t *testing.T) {
+	tests := []vmTestCase{
+		{`
+		let newClosure = fn(a) { fn() { a; }; };
+		let closure = newClosure(99);
+		closure();`, 99},
+		{`
+		let newAdder = fn(a, b) { fn(c) { a + b + c }; };
+		let adder = newAdder(1, 2);
+		adder(8);`, 11},
+		{`
+		let newAdder = fn(a, b) { let c = a + b; fn(d) { c + d }; };
+		let adder = newAdder(1, 2);
+		adder(8);`, 11},
+		{`
+		let newAdderOuter = fn(a, b) {
+			let c = a + b;
+			fn(d) {
+				let e = d + c;
+				fn(f) { e + f; };
+			};
+		};
+		let newAdderInner = newAdderOuter(1, 2)
+		let adder = newAdderInner(3);
+		adder(8);`, 14},
+		{`
+		let a = 1;
+		let newAdderOuter = fn(b) {
+			fn(c) {
+				fn(d) { a + b + c + d };
+			};
+		};
+		let newAdderInner = newAdderOuter(2)
+		let adder = newAdderInner(3);
+		adder(8);`, 14},
+		{`
+		let newClosure = fn(a, b) {
+			let one = fn() { a; };
+			let two = fn() { b; };
+			fn() { one() + two(); };
+		};
+		let closure = newClosure(9, 90);
+		closure();`, 99},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestRecursiveFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{`
+		let countDown = fn(x) {
+			if (x == 0) {
+				return 0;
+			} else {
+				countDown(x - 1);
+			}
+		};
+		countDown(1);`, 0},
+		{`
+		let wrapper = fn() {
+			let countDown = fn(x) {
+				if (x == 0) {
+					return 0;
+				} else {
+					countDown(x - 1);
+				}
+			};
+			countDown(1);
+		};
+		wrapper();`, 0},
+		{`
+		let fibonacci = fn(x) {
+			if (x == 0) {
+				return 0;
+			} else {
+				if (x == 1) {
+					return 1;
+				} else {
+					fibonacci(x - 1) + fibonacci(x - 2);
+				}
+			}
+		};
+		fibonacci(15);`, 610},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len("hello world")`, 11},
+		{`len("héllo wörld")`, 11},
+		{`len([1, 2, 3])`, 3},
+		{`len([])`, 0},
+		{`puts("hello", "world!")`, Null},
+		{`head([1, 2, 3])`, 1},
+		{`head([])`, Null},
+		{`last([1, 2, 3])`, 3},
+		{`last([])`, Null},
+		{`tail([1, 2, 3])`, []int{2, 3}},
+		{`tail([])`, Null},
+		{`push([], 1)`, []int{1}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestWhileStatements(t *testing.T) {
+	tests := []vmTestCase{
+		{"let i = 0; while (i < 5) { let i = i + 1; }; i", 5},
+		{"let i = 0; while (true) { let i = i + 1; if (i > 3) { break; } }; i", 4},
+		{"let i = 0; let s = 0; while (i < 5) { let i = i + 1; if (i == 2) { continue; } let s = s + i; }; s", 13},
+		{"let f = fn() { while (true) { return 7; } }; f()", 7},
+		{"let f = fn() { while (false) { } }; f()", Null},
+		{`
+		let f = fn(n) {
+			let i = 0;
+			let s = 0;
+			while (i < n) { let i = i + 1; let s = s + i; }
+			s
+		};
+		f(100)`, 5050},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestForInStatements(t *testing.T) {
+	tests := []vmTestCase{
+		{"let s = 0; for (x in [1, 2, 3]) { let s = s + x; }; s", 6},
+		{"let s = 0; for (x in [1, 2, 3, 4]) { if (x == 2) { continue; } if (x == 4) { break; } let s = s + x; }; s", 4},
+		{`let s = ""; for (k in {"b": 1, "a": 2, "c": 3}) { let s = s + k; }; s`, "abc"},
+		{`let s = ""; for (c in "abc") { let s = c + s; }; s`, "cba"},
+		{"let f = fn(arr) { for (x in arr) { if (x > 1) { return x; } } }; f([1, 5, 9])", 5},
+		{`
+		let f = fn(rows) {
+			let s = 0;
+			for (row in rows) {
+				for (x in row) {
+					if (x == 0) { break; }
+					let s = s + x;
+				}
+			}
+			s
+		};
+		f([[1, 2, 0, 9], [3, 0], [4]])`, 10},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"let a = 1; a = 2; a", 2},
+		{"let a = 1; let b = a = 3; a + b", 6},
+		{"let a = 1; let f = fn() { a = a + 1; }; f(); f(); a", 3},
+		{"let f = fn() { let a = 1; a = a + 1; a }; f()", 2},
+		{"let f = fn() { let n = 0; fn() { n = n + 1; n } }; let c = f(); c(); c()", 2},
+		{"let i = 0; while (i < 5) { i = i + 1; }; i", 5},
+		{"let arr = [1, 2, 3]; arr[1] = 5; arr[0] + arr[1] + arr[2]", 9},
+		{`let h = {"a": 1}; h["b"] = 2; h["a"] = h["a"] + 10; h["a"] + h["b"]`, 13},
+		{"let grid = [[0, 0], [0, 0]]; grid[1][0] = 7; grid[1][0]", 7},
+		{"let f = fn(arr) { arr[0] = 42; }; let a = [1]; f(a); a[0]", 42},
+		{"let f = fn() { let x = 1; let g = fn() { x = x + 1; x }; g(); g(); x }; f()", 3},
+		{"let f = fn() { let x = 1; let g = fn() { x }; x = 5; g() }; f()", 5},
+		{"let f = fn(x) { let g = fn() { x = x * 2; }; g(); g(); x }; f(2)", 8},
+		{"let f = fn() { let x = 1; fn() { fn() { x = x + 1; x } } }; let g = f(); g()(); g()()", 3},
+		{"let f = fn() { f = 10; }; f(); f", 10},
+		{"let f = fn() { let g = fn() { g = 7; }; g(); g }; f()", 7},
+		{"let f = fn(n) { if (n == 0) { 1 } else { n * f(n - 1) } }; f(5)", 120},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestCallingFunctionsWithWrongArguments(t *testing.T) {
+	tests := []vmTestCase{
+		{"fn() { 1; }(1);", "1:12: wrong number of arguments: want=0, got=1"},
+		{"fn(a) { 1; }();", "1:13: wrong number of arguments: want=1, got=0"},
+		{"1();", "1:2: calling non-function"},
+		{"let f = fn() {\n  5 + true\n};\nf();", "2:5: unsupported types for binary operation: left=INTEGER, right=BOOLEAN"},
+		{"for (x in 5) { }", "1:1: type INTEGER is not iterable"},
+		{"10 % 0", "1:4: division by zero"},
+		{"let arr = [1];\narr[1] = 2;", "2:8: index out of range: 1"},
+		{"len(1)", "1:4: argument to `len` not supported. got INTEGER"},
+		{`len("one", "two")`, "1:4: wrong number of arguments. got=2, want=1"},
+		{"head(1)", "1:5: argument to `head` must be ARRAY, got=INTEGER"},
+		{"let f = fn() {\n  push(1, 1)\n};\nf(); 5", "2:7: argument to `push` must be ARRAY, got=INTEGER"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.ByteCode())
+		err = vm.Run()
+		if err == nil {
+			t.Fatalf("expected VM error but resulted in none.")
+		}
+
+		if err.Error() != tt.expected {
+			t.Fatalf("wrong VM error: want=%q, got=%q", tt.expected, err)
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	timeout, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	tests := []struct {
+		input    string
+		ctx      context.Context
+		limits   object.Limits
+		expected error
+	}{
+		{"while (true) { }", context.Background(), object.Limits{MaxSteps: 1000}, &object.StepLimitError{}},
+		{"while (true) { }", timeout, object.Limits{}, context.DeadlineExceeded},
+		{"let f = fn(n) { f(n + 1) }; f(0)", context.Background(), object.Limits{MaxCallDepth: 100}, &object.CallDepthError{}},
+		{`let s = "abc"; s + s`, context.Background(), object.Limits{MaxAllocSize: 5}, &object.AllocLimitError{}},
+		{`let s = "abc"; "${s}${s}"`, context.Background(), object.Limits{MaxAllocSize: 5}, &object.AllocLimitError{}},
+		{"[1, 2, 3]", context.Background(), object.Limits{MaxAllocSize: 2}, &object.AllocLimitError{}},
+		{"push([1, 2], 3)", context.Background(), object.Limits{MaxAllocSize: 2}, &object.AllocLimitError{}},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.ByteCode())
+		err = vm.RunContext(tt.ctx, tt.limits)
+		if err == nil {
+			t.Errorf("no error for %q", tt.input)
+			continue
+		}
+
+		if !isLimitError(err, tt.expected) {
+			t.Errorf("wrong error for %q. want=%T, got=%T (%s)", tt.input, tt.expected, errors.Unwrap(err), err)
+		}
+	}
+
+	comp := compiler.New()
+	err := comp.Compile(parse("let f = fn(n) { if (n > 0) { f(n - 1) } else { 0 } }; f(10)"))
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.ByteCode())
+	err = vm.RunContext(context.Background(), object.Limits{MaxCallDepth: 11, MaxSteps: 1000})
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	testExpectObject(t, 0, vm.LastPoppedStackElem())
+}
+
+func isLimitError(err, expected error) bool {
+	switch expected.(type) {
+	case *object.StepLimitError:
+		var target *object.StepLimitError
+		return errors.As(err, &target)
+	case *object.CallDepthError:
+		var target *object.CallDepthError
+		return errors.As(err, &target)
+	case *object.AllocLimitError:
+		var target *object.AllocLimitError
+		return errors.As(err, &target)
+	}
+	return errors.Is(err, expected)
+}
+
+func TestImports(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"math.gonk":    "let two = 2; let double = fn(n) { n * two };",
+		"counter.gonk": "let count = 0; let inc = fn() { count = count + 1 };",
+		"nested.gonk":  `import "./math.gonk" as m; let quad = fn(n) { m.double(m.double(n)) };`,
+		"bad.gonk":     "let f = fn() { 1 + true };",
+	})
+	math := filepath.Join(dir, "math.gonk")
+	counter := filepath.Join(dir, "counter.gonk")
+	bad := filepath.Join(dir, "bad.gonk")
+
+	runVmTests(t, []vmTestCase{
+		{fmt.Sprintf("import %q as math; math.double(math.two)", math), 4},
+		{fmt.Sprintf("import %q as nested; nested.quad(3)", filepath.Join(dir, "nested.gonk")), 12},
+		{fmt.Sprintf("import %q as a; import %q as b; a.inc(); b.inc(); a.inc()", counter, counter), 3},
+		{fmt.Sprintf("let two = 5; import %q as math; two + math.two", math), 7},
+	})
+
+	errors := []vmTestCase{
+		{fmt.Sprintf("import %q as math; math.three", math), fmt.Sprintf("1:%d: module %q has no member three", len(math)+24, math)},
+		{fmt.Sprintf("import %q as bad; bad.f()", bad), bad + ":1:18: unsupported types for binary operation: left=INTEGER, right=BOOLEAN"},
+	}
+
+	for _, tt := range errors {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.ByteCode())
+		err = vm.Run()
+		if err == nil {
+			t.Fatalf("expected VM error but resulted in none.")
+		}
+
+		if err.Error() != tt.expected {
+			t.Fatalf("wrong VM error: want=%q, got=%q", tt.expected, err)
+		}
+	}
+}
+
+func TestImportCycle(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"a.gonk": `import "./b.gonk" as b;`,
+		"b.gonk": `import "./a.gonk" as a;`,
+	})
+
+	comp := compiler.New()
+	err := comp.Compile(parse(fmt.Sprintf("import %q as a;", filepath.Join(dir, "a.gonk"))))
+	if err == nil {
+		t.Fatalf("expected compiler error but resulted in none.")
+	}
+
+	expected := filepath.Join(dir, "b.gonk") + `:1:1: import cycle: "./a.gonk" is already being imported`
+	if err.Error() != expected {
+		t.Fatalf("wrong compiler error: want=%q, got=%q", expected, err)
+	}
+}
+
+// writeModules writes the source files to a temporary directory and returns it.
+func writeModules(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, source := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(source), 0644)
+		if err != nil {
+			t.Fatalf("could not write module: %s", err)
+		}
+	}
+	return dir
+}
+
+func TestOptimizedBytecode(t *testing.T) {
+	tests := []string{
+		"1 + 2 * 3 - 4 / 2 % 3",
+		"-(1.5 + 1) * 2 >= 4",
+		`"a" + "b" + "${1 + 1}c${true}"`,
+		"!true; !!5; !(1 > 2) == true",
+		"1 / 0",
+		"10 % 0 + 1",
+		"if (1 > 2) { 10 } else { 20 }",
+		"if (1 < 2) { 10 }",
+		"if (false) { 10 }",
+		"true && false; false || 1 < 2; 0 && 1",
+		"let i = 0; while (true) { let i = i + 1; if (i > 3) { break; } }; i",
+		"let i = 0; while (false) { let i = 9; }; i",
+		"let i = 0; let s = 0; while (i < 5) { let i = i + 1; if (i == 2) { continue; } let s = s + i; }; s",
+		"let s = 0; for (x in [1, 2, 3, 4]) { if (x == 2) { continue; } if (x == 4) { break; } let s = s + x; }; s",
+		"let f = fn(x) { if (true) { return x * (2 + 3); } x }; f(4)",
+		"let f = fn() { while (true) { return 7; } }; f()",
+		"let f = fn() { return 1; 2 + 3 }; f()",
+		"let fib = fn(n) { if (n < 2) { return n; } fib(n - 1) + fib(n - 2) }; fib(10)",
+		"let f = fn(a) { fn(b) { a + b + (1 + 1) } }; f(1)(2)",
+		"let h = {1 + 1: 2 * 2}; h[2]",
+		"[1 + 1, 2 * 2][1]",
+		"-true",
+		`"a" + "b" == "ab"; "a" != "b"`,
+		`let x = "a"; x + "b" == "ab"`,
+		`let s = ""; let a = fn() { s = s + "a"; 1 }; let b = fn() { s = s + "b"; 2 }; a() < b(); a() <= b(); s`,
+	}
+
+	for _, input := range tests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		bytecode := comp.ByteCode()
+
+		want, wantErr := runBytecode(bytecode)
+		got, gotErr := runBytecode(compiler.Optimize(bytecode))
+
+		if want != got || fmt.Sprint(wantErr) != fmt.Sprint(gotErr) {
+			t.Errorf("%s: optimized result differs. want=%s (%v), got=%s (%v)", input, want, wantErr, got, gotErr)
+		}
+	}
+}
+
+// runBytecode returns the inspected last popped value and the error of running bytecode.
+func runBytecode(bytecode *compiler.ByteCode) (string, error) {
+	vm := New(bytecode)
+	if err := vm.Run(); err != nil {
+		return "", err
+	}
+	return vm.LastPoppedStackElem().Inspect(), nil
+}
+
+func TestWideConstants(t *testing.T) {
+	constants := make([]object.Object, 65536)
+	for i := range constants {
+		constants[i] = &object.Float{Value: float64(i)}
+	}
+
+	comp := compiler.NewWithState(compiler.NewSymbolTable(), constants)
+	if err := comp.Compile(parse(`let f = fn(x) { x + 1 }; "n" + "${f(2) * 2}"`)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	bytecode := comp.ByteCode()
+
+	for _, bytecode := range []*compiler.ByteCode{bytecode, compiler.Optimize(bytecode)} {
+		result, err := runBytecode(bytecode)
+		if err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+		if result != "n6" {
+			t.Errorf("wrong result. want=%q, got=%q", "n6", result)
+		}
+	}
+}
+
+func TestWideJumps(t *testing.T) {
+	// each statement takes 11 bytes, so the branches below are past 64 KB
+	body := strings.Repeat("a = a + 1;\n", 7000)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let a = 0;\n" + body + "if (a > 5) { a } else { 0 }", "7000"},
+		{"let a = 0;\n" + body + "let i = 0; while (i < 3) { i = i + 1; }; if (a > 1 && a < 9000) { i }", "3"},
+		{"let a = 0;\n" + body + `let s = ""; for (x in [1, 2]) { if (x == 2) { break; } s = s + "${x}"; }; s`, "1"},
+		{"let a = 0;\nif (a == 0) {\n" + body + "}; a", "7000"},
+		{"let f = fn() { let a = 0;\n" + body + "if (a < 0 || false) { 1 } else { a } }; f()", "7000"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		bytecode := comp.ByteCode()
+
+		for _, bytecode := range []*compiler.ByteCode{bytecode, compiler.Optimize(bytecode)} {
+			result, err := runBytecode(bytecode)
+			if err != nil {
+				t.Fatalf("vm error: %s", err)
+			}
+			if result != tt.expected {
+				t.Errorf("wrong result. want=%q, got=%q", tt.expected, result)
+			}
+		}
+	}
+}
+
+func TestPersistentState(t *testing.T) {
+	symbolTable := compiler.NewSymbolTable()
+	for i, v := range object.Builtins {
+		symbolTable.DefineBuiltin(i, v.Name)
+	}
+	constants := []object.Object{}
+	globals := make([]object.Object, GlobalSize)
+
+	inputs := []vmTestCase{
+		{"let a = 2;", 2},
+		{"let double = fn(x) { x * a };", nil},
+		{"double(21)", 42},
+	}
+
+	for _, tt := range inputs {
+		comp := compiler.NewWithState(symbolTable, constants)
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		bytecode := comp.ByteCode()
+		constants = bytecode.Constants
+
+		vm := NewWithGlobalsStore(bytecode, globals)
+		err = vm.Run()
+		if err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+
+		if tt.expected != nil {
+			testExpectObject(t, tt.expected, vm.LastPoppedStackElem())
+		}
+	}
+}
+
 func runVmTests(t *testing.T, tests []vmTestCase) {
 	for _, tt := range tests {
 		program := parse(tt.input)
@@ -185,6 +841,11 @@ func testExpectObject(t *testing.T, expected interface{}, actual object.Object)
 		if err != nil {
 			t.Errorf("testIntegerObject error: %s", err)
 		}
+	case float64:
+		err := testFloatObject(expected, actual)
+		if err != nil {
+			t.Errorf("testFloatObject error: %s", err)
+		}
 	case string:
 		err := testStringObject(expected, actual)
 		if err != nil {
@@ -227,6 +888,17 @@ func testExpectObject(t *testing.T, expected interface{}, actual object.Object)
 		if actual != Null {
 			t.Errorf("object in not a Null obj: %T (%+v)", actual, actual)
 		}
+	case *object.Error:
+		errObj, ok := actual.(*object.Error)
+		if !ok {
+			t.Errorf("object is not Error: %T (%+v)", actual, actual)
+			return
+		}
+
+		if errObj.Message != expected.Message {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				expected.Message, errObj.Message)
+		}
 	}
 }
 
@@ -249,6 +921,19 @@ func testIntegerObject(expected int64, actual object.Object) error {
 	return nil
 }
 
+func testFloatObject(expected float64, actual object.Object) error {
+	result, ok := actual.(*object.Float)
+	if !ok {
+		return fmt.Errorf("object is not Float. got=%T (%+v)",
+			actual, actual)
+	}
+	if result.Value != expected {
+		return fmt.Errorf("object has wrong value. got=%g, want=%g",
+			result.Value, expected)
+	}
+	return nil
+}
+
 func testStringObject(expected string, actual object.Object) error {
 	result, ok := actual.(*object.String)
 	if !ok {